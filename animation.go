@@ -0,0 +1,281 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+
+	"github.com/muesli/smartcrop/options"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	// ErrNoFrames gets returned when an animation without any frames is analyzed
+	ErrNoFrames = errors.New("animation has no frames")
+	// ErrFrameBounds gets returned when the frames of an animation differ in size
+	ErrFrameBounds = errors.New("all frames must have the same bounds")
+)
+
+// AnimationAnalyzer is implemented by analyzers which can find a single crop
+// that suits all frames of an animation
+type AnimationAnalyzer interface {
+	Analyzer
+	FindBestAnimationCrops(frames []image.Image, width, height, count int) ([]Crop, error)
+}
+
+// FindBestAnimationCrops returns up to count crops for the frames, which must
+// all have the same bounds. The crops are scored on the features averaged over
+// all frames, so the best crop covers what is important throughout the
+// animation.
+func (o smartcropAnalyzer) FindBestAnimationCrops(frames []image.Image, width, height, count int) ([]Crop, error) {
+	if len(frames) == 0 {
+		return nil, ErrNoFrames
+	}
+	for _, f := range frames[1:] {
+		if f.Bounds() != frames[0].Bounds() {
+			return nil, ErrFrameBounds
+		}
+	}
+	return o.findBestCrops(frames, width, height, count)
+}
+
+// FindBestGIFCrop returns the best crop for an animated GIF, analyzing up to
+// samples evenly spaced frames, or all frames if samples is 0. Analyzers which
+// don't implement AnimationAnalyzer only get to see the first frame.
+func FindBestGIFCrop(a Analyzer, g *gif.GIF, width, height, samples int) (image.Rectangle, error) {
+	frames := GIFFrames(g, samples)
+	if len(frames) == 0 {
+		return image.Rectangle{}, ErrNoFrames
+	}
+
+	aa, ok := a.(AnimationAnalyzer)
+	if !ok {
+		return a.FindBestCrop(frames[0], width, height)
+	}
+	crops, err := aa.FindBestAnimationCrops(frames, width, height, 1)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return crops[0].Rectangle, nil
+}
+
+// GIFFrames returns the frames of g as they are displayed, i.e. with the
+// previous frames and their disposal applied. With samples greater than 0 at
+// most that many evenly spaced frames are returned, starting with the first
+// one.
+func GIFFrames(g *gif.GIF, samples int) []image.Image {
+	n := len(g.Image)
+	if samples <= 0 || samples > n {
+		samples = n
+	}
+
+	var frames []image.Image
+	compositeGIF(g, func(i int, canvas *image.RGBA) {
+		// the k-th sample is frame k*n/samples
+		if len(frames) < samples && i == len(frames)*n/samples {
+			frame := image.NewRGBA(canvas.Bounds())
+			copy(frame.Pix, canvas.Pix)
+			frames = append(frames, frame)
+		}
+	})
+	return frames
+}
+
+// CropGIF crops all frames of g to r and, unless resizer is nil, scales them
+// to width x height. Delays and the loop count are kept. Every frame of the
+// result is a complete picture, so frames are only disposed of if the next
+// frame has transparent pixels, which must not show the frame below.
+func CropGIF(g *gif.GIF, r image.Rectangle, width, height int, resizer options.Resizer) *gif.GIF {
+	if resizer == nil {
+		width, height = r.Dx(), r.Dy()
+	}
+
+	out := &gif.GIF{
+		Delay:           append([]int(nil), g.Delay...),
+		LoopCount:       g.LoopCount,
+		BackgroundIndex: g.BackgroundIndex,
+		Config: image.Config{
+			ColorModel: g.Config.ColorModel,
+			Width:      width,
+			Height:     height,
+		},
+	}
+
+	// the canvas can show colors of all frames drawn so far, so the palettes
+	// of those are candidates for the palette of a composite frame
+	var palettes []color.Palette
+	if p, ok := g.Config.ColorModel.(color.Palette); ok {
+		palettes = append(palettes, p)
+	}
+
+	// every frame gets replaced by the full cropped canvas
+	var transparent []bool
+	compositeGIF(g, func(i int, canvas *image.RGBA) {
+		palettes = append(palettes, g.Image[i].Palette)
+		var img image.Image = canvas.SubImage(r)
+		if resizer != nil && (r.Dx() != width || r.Dy() != height) {
+			img = resizer.Resize(img, uint(width), uint(height))
+		}
+
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), framePalette(compositePalette(palettes, img), img))
+		draw.Draw(frame, frame.Bounds(), img, img.Bounds().Min, draw.Src)
+		out.Image = append(out.Image, frame)
+		transparent = append(transparent, hasTransparency(frame))
+	})
+
+	// the first frame follows the last one when looping
+	for i := range out.Image {
+		disposal := byte(gif.DisposalNone)
+		if transparent[(i+1)%len(out.Image)] {
+			disposal = gif.DisposalBackground
+		}
+		out.Disposal = append(out.Disposal, disposal)
+	}
+	return out
+}
+
+// hasTransparency reports whether any pixel of p is transparent.
+func hasTransparency(p *image.Paletted) bool {
+	var clear [256]bool
+	for i, c := range p.Palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			clear[i] = true
+		}
+	}
+	for _, i := range p.Pix {
+		if clear[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// compositeGIF renders the frames of g one after another and calls fn with
+// the canvas as it is displayed for each frame.
+func compositeGIF(g *gif.GIF, fn func(i int, canvas *image.RGBA)) {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		for _, f := range g.Image {
+			bounds = bounds.Union(f.Bounds())
+		}
+	}
+
+	canvas := image.NewRGBA(bounds)
+	var previous *image.RGBA
+	for i, f := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, f.Bounds(), f, f.Bounds().Min, draw.Over)
+		fn(i, canvas)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, f.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, previous.Pix)
+		}
+	}
+}
+
+// compositePalette returns a palette for img, which is composed of frames
+// using the given palettes. If img has no more than 256 colors, those are
+// returned. Otherwise the palettes get merged, preferring the later ones, as
+// they belong to the frames drawn last.
+func compositePalette(palettes []color.Palette, img image.Image) color.Palette {
+	seen := make(map[color.RGBA]bool)
+	var p color.Palette
+
+	b := img.Bounds()
+	exact := true
+	for y := b.Min.Y; y < b.Max.Y && exact; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if seen[c] {
+				continue
+			}
+			if len(p) == 256 {
+				exact = false
+				break
+			}
+			seen[c] = true
+			p = append(p, c)
+		}
+	}
+	if exact {
+		return p
+	}
+
+	seen = make(map[color.RGBA]bool)
+	p = nil
+	for i := len(palettes) - 1; i >= 0 && len(p) < 256; i-- {
+		for _, c := range palettes[i] {
+			rgba := color.RGBAModel.Convert(c).(color.RGBA)
+			if seen[rgba] {
+				continue
+			}
+			seen[rgba] = true
+			p = append(p, c)
+			if len(p) == 256 {
+				break
+			}
+		}
+	}
+	return p
+}
+
+// framePalette returns p, extended by a transparent color if img has
+// transparent pixels p can't represent.
+func framePalette(p color.Palette, img image.Image) color.Palette {
+	for _, c := range p {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return p
+		}
+	}
+	if len(p) >= 256 {
+		return p
+	}
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a == 0 {
+				return append(append(color.Palette(nil), p...), color.RGBA{})
+			}
+		}
+	}
+	return p
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"context"
+	"errors"
+	"image"
+	"runtime"
+	"sync"
+)
+
+var (
+	// ErrNoImage gets returned for a Job which has neither an Image nor Open set
+	ErrNoImage = errors.New("job has neither an image nor an open function")
+	// ErrNoAnalyzer gets returned for every Job of a Batch without an Analyzer
+	ErrNoAnalyzer = errors.New("batch has no analyzer")
+)
+
+// Job is a single image to be cropped by a Batch.
+type Job struct {
+	// ID identifies the job in its Result and is not used otherwise.
+	ID interface{}
+
+	// Image is the image to analyze. If it is nil, Open gets called by the
+	// worker processing the job, so only as many images are held in memory
+	// as there are workers. A job with neither fails with ErrNoImage.
+	Image image.Image
+	Open  func() (image.Image, error)
+
+	Width  int
+	Height int
+}
+
+// Result is the outcome of a Job. If Err is nil, Crop is the best crop found.
+// Its score is only set if the Analyzer implements CropsAnalyzer.
+type Result struct {
+	Job  Job
+	Crop Crop
+	Err  error
+}
+
+// Batch crops many images concurrently.
+type Batch struct {
+	// Analyzer finds the crops. It gets used by all workers at the same
+	// time, so it must be safe for concurrent use. Without an Analyzer all
+	// jobs fail with ErrNoAnalyzer.
+	Analyzer Analyzer
+
+	// Workers is the number of jobs processed in parallel. It defaults to
+	// the number of CPUs.
+	Workers int
+
+	// Progress, if set, gets called after the result of every job has been
+	// delivered, with the result and the number of jobs done and failed so
+	// far. Calls never overlap.
+	Progress func(r Result, done, failed int)
+}
+
+// Run processes the jobs read from jobs until the channel is closed or ctx is
+// cancelled and returns a channel delivering a Result per job, in the order
+// they are done. The channel gets closed once all jobs have been processed.
+//
+// Jobs are only read when a worker is free and a worker only continues once
+// its result has been received, so a slow consumer slows down the whole
+// batch instead of piling up results in memory.
+func (b *Batch) Run(ctx context.Context, jobs <-chan Job) <-chan Result {
+	workers := b.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make(chan Result)
+	var mu sync.Mutex
+	var done, failed int
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var j Job
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case j, ok = <-jobs:
+					if !ok {
+						return
+					}
+				}
+
+				r := b.process(j)
+				select {
+				case <-ctx.Done():
+					return
+				case results <- r:
+				}
+
+				// only delivered results count, a cancelled batch may
+				// have dropped the last ones
+				if b.Progress != nil {
+					mu.Lock()
+					done++
+					if r.Err != nil {
+						failed++
+					}
+					b.Progress(r, done, failed)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// process crops the image of a single job.
+func (b *Batch) process(j Job) Result {
+	r := Result{Job: j}
+	if b.Analyzer == nil {
+		r.Err = ErrNoAnalyzer
+		return r
+	}
+
+	img := j.Image
+	if img == nil {
+		if j.Open == nil {
+			r.Err = ErrNoImage
+			return r
+		}
+		var err error
+		if img, err = j.Open(); err != nil {
+			r.Err = err
+			return r
+		}
+	}
+
+	if ca, ok := b.Analyzer.(CropsAnalyzer); ok {
+		crops, err := ca.FindBestCrops(img, j.Width, j.Height, 1)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		r.Crop = crops[0]
+		return r
+	}
+
+	r.Crop.Rectangle, r.Err = b.Analyzer.FindBestCrop(img, j.Width, j.Height)
+	return r
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+// Package cache provides an Analyzer which remembers the crops found for an
+// image, so analyzing the same image again is cheap.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"image"
+	"math"
+
+	"github.com/muesli/smartcrop"
+)
+
+// Cache stores the results of the analysis. Caches are best-effort: a value
+// which can't be stored or loaded simply gets computed again. Implementations
+// must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored for key, if any.
+	Get(key string) ([]byte, bool)
+	// Set stores value for key.
+	Set(key string, value []byte)
+}
+
+// Analyzer looks up crops in a Cache before running the wrapped Analyzer.
+// Results are keyed by a hash of the image content, the requested crop, the
+// boosts, detectors and text mode added to it and the options passed to
+// NewAnalyzer.
+type Analyzer struct {
+	analyzer  smartcrop.Analyzer
+	cache     Cache
+	options   string
+	boosts    []smartcrop.Boost
+	detectors []string
+	textMode  smartcrop.TextMode
+}
+
+// NewAnalyzer returns an Analyzer caching the results of a in c. The options
+// must identify the configuration of a: analyzers which may find different
+// crops for the same image need to use different options, or a different
+// cache.
+func NewAnalyzer(a smartcrop.Analyzer, c Cache, options string) *Analyzer {
+	return &Analyzer{
+		analyzer: a,
+		cache:    c,
+		options:  options,
+	}
+}
+
+// FindBestCrop returns the best crop, from the cache if possible.
+func (a *Analyzer) FindBestCrop(img image.Image, width, height int) (image.Rectangle, error) {
+	crops, err := a.FindBestCrops(img, width, height, 1)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return crops[0].Rectangle, nil
+}
+
+// FindBestCrops returns up to count of the best crops, from the cache if
+// possible. If the wrapped Analyzer doesn't implement
+// smartcrop.CropsAnalyzer, only the best crop is returned.
+func (a *Analyzer) FindBestCrops(img image.Image, width, height, count int) ([]smartcrop.Crop, error) {
+	return a.cached([]image.Image{img}, width, height, count, func() ([]smartcrop.Crop, error) {
+		if ca, ok := a.analyzer.(smartcrop.CropsAnalyzer); ok {
+			return ca.FindBestCrops(img, width, height, count)
+		}
+		r, err := a.analyzer.FindBestCrop(img, width, height)
+		if err != nil {
+			return nil, err
+		}
+		return []smartcrop.Crop{{Rectangle: r}}, nil
+	})
+}
+
+// FindBestAnimationCrops returns up to count of the best crops for the frames
+// of an animation, from the cache if possible. If the wrapped Analyzer doesn't
+// implement smartcrop.AnimationAnalyzer, only the first frame is analyzed.
+func (a *Analyzer) FindBestAnimationCrops(frames []image.Image, width, height, count int) ([]smartcrop.Crop, error) {
+	aa, ok := a.analyzer.(smartcrop.AnimationAnalyzer)
+	if !ok {
+		if len(frames) == 0 {
+			return nil, smartcrop.ErrNoFrames
+		}
+		return a.FindBestCrops(frames[0], width, height, count)
+	}
+	return a.cached(frames, width, height, count, func() ([]smartcrop.Crop, error) {
+		return aa.FindBestAnimationCrops(frames, width, height, count)
+	})
+}
+
+// cached returns the crops stored for the request, or stores the crops
+// returned by find.
+func (a *Analyzer) cached(frames []image.Image, width, height, count int, find func() ([]smartcrop.Crop, error)) ([]smartcrop.Crop, error) {
+	key := a.key(frames, width, height, count)
+	if b, ok := a.cache.Get(key); ok {
+		var crops []smartcrop.Crop
+		if err := json.Unmarshal(b, &crops); err == nil && len(crops) > 0 {
+			return crops, nil
+		}
+	}
+
+	crops, err := find()
+	if err != nil {
+		return nil, err
+	}
+	if b, err := json.Marshal(crops); err == nil {
+		a.cache.Set(key, b)
+	}
+	return crops, nil
+}
+
+// WithBoosts returns a copy of the Analyzer passing boosts on to the wrapped
+// Analyzer. The boosts become part of the cache key. If the wrapped Analyzer
+// doesn't implement smartcrop.BoostAnalyzer, a is returned unchanged.
+func (a *Analyzer) WithBoosts(boosts []smartcrop.Boost) smartcrop.Analyzer {
+	ba, ok := a.analyzer.(smartcrop.BoostAnalyzer)
+	if !ok {
+		return a
+	}
+	c := *a
+	c.analyzer = ba.WithBoosts(boosts)
+	c.boosts = append([]smartcrop.Boost(nil), boosts...)
+	return &c
+}
+
+// WithDetector returns a copy of the Analyzer passing d on to the wrapped
+// Analyzer. The type, value and weight of d become part of the cache key, so
+// detectors need to be comparable by their printed value. If the wrapped
+// Analyzer doesn't implement smartcrop.DetectorAnalyzer, a is returned
+// unchanged.
+func (a *Analyzer) WithDetector(d smartcrop.Detector, weight float64) smartcrop.Analyzer {
+	da, ok := a.analyzer.(smartcrop.DetectorAnalyzer)
+	if !ok {
+		return a
+	}
+	c := *a
+	c.analyzer = da.WithDetector(d, weight)
+	c.detectors = append(append([]string(nil), a.detectors...), fmt.Sprintf("%T%+v:%g", d, d, weight))
+	return &c
+}
+
+// WithTextMode returns a copy of the Analyzer passing mode on to the wrapped
+// Analyzer. The mode becomes part of the cache key. If the wrapped Analyzer
+// doesn't implement smartcrop.TextAnalyzer, a is returned unchanged.
+func (a *Analyzer) WithTextMode(mode smartcrop.TextMode) smartcrop.Analyzer {
+	ta, ok := a.analyzer.(smartcrop.TextAnalyzer)
+	if !ok {
+		return a
+	}
+	c := *a
+	c.analyzer = ta.WithTextMode(mode)
+	c.textMode = mode
+	return &c
+}
+
+// key returns the cache key of a request.
+func (a *Analyzer) key(frames []image.Image, width, height, count int) string {
+	h := sha256.New()
+	writeString(h, a.options)
+	writeInts(h, width, height, count, len(a.boosts))
+	for _, b := range a.boosts {
+		writeInts(h, b.Min.X, b.Min.Y, b.Max.X, b.Max.Y)
+		writeInts(h, int(math.Float64bits(b.Weight)))
+	}
+	writeInts(h, len(a.detectors))
+	for _, d := range a.detectors {
+		writeString(h, d)
+	}
+	writeInts(h, int(a.textMode))
+	writeInts(h, len(frames))
+	for _, img := range frames {
+		hashImage(h, img)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashImage writes the bounds and pixels of img to h.
+func hashImage(h hash.Hash, img image.Image) {
+	bounds := img.Bounds()
+	writeInts(h, bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)
+
+	// common image types get hashed straight from their pixel buffers
+	switch i := img.(type) {
+	case *image.RGBA:
+		writeString(h, "rgba")
+		hashRows(h, i.Pix, i.Stride, i.PixOffset(bounds.Min.X, bounds.Min.Y), bounds.Dx()*4, bounds.Dy())
+		return
+	case *image.NRGBA:
+		writeString(h, "nrgba")
+		hashRows(h, i.Pix, i.Stride, i.PixOffset(bounds.Min.X, bounds.Min.Y), bounds.Dx()*4, bounds.Dy())
+		return
+	case *image.Gray:
+		writeString(h, "gray")
+		hashRows(h, i.Pix, i.Stride, i.PixOffset(bounds.Min.X, bounds.Min.Y), bounds.Dx(), bounds.Dy())
+		return
+	}
+
+	writeString(h, "generic")
+	row := make([]byte, bounds.Dx()*8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			i := (x - bounds.Min.X) * 8
+			binary.LittleEndian.PutUint16(row[i:], uint16(r))
+			binary.LittleEndian.PutUint16(row[i+2:], uint16(g))
+			binary.LittleEndian.PutUint16(row[i+4:], uint16(b))
+			binary.LittleEndian.PutUint16(row[i+6:], uint16(a))
+		}
+		_, _ = h.Write(row)
+	}
+}
+
+func hashRows(h hash.Hash, pix []byte, stride, offset, rowLen, rows int) {
+	for y := 0; y < rows; y++ {
+		_, _ = h.Write(pix[offset : offset+rowLen])
+		offset += stride
+	}
+}
+
+func writeInts(h hash.Hash, values ...int) {
+	var b [8]byte
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		_, _ = h.Write(b[:])
+	}
+}
+
+func writeString(h hash.Hash, s string) {
+	writeInts(h, len(s))
+	_, _ = h.Write([]byte(s))
+}
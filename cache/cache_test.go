@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package cache
+
+import (
+	"image"
+	_ "image/jpeg"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/nfnt"
+)
+
+// countingAnalyzer counts the calls to the analyzer it wraps.
+type countingAnalyzer struct {
+	smartcrop.Analyzer
+	calls int
+}
+
+func (a *countingAnalyzer) FindBestCrops(img image.Image, width, height, count int) ([]smartcrop.Crop, error) {
+	a.calls++
+	return a.Analyzer.(smartcrop.CropsAnalyzer).FindBestCrops(img, width, height, count)
+}
+
+func (a *countingAnalyzer) WithBoosts(boosts []smartcrop.Boost) smartcrop.Analyzer {
+	return &countingAnalyzer{Analyzer: a.Analyzer.(smartcrop.BoostAnalyzer).WithBoosts(boosts)}
+}
+
+func decodeGopher(t *testing.T) image.Image {
+	f, err := os.Open("../examples/gopher.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestAnalyzer(t *testing.T) {
+	img := decodeGopher(t)
+	inner := &countingAnalyzer{Analyzer: smartcrop.NewAnalyzer(nfnt.NewDefaultResizer())}
+	a := NewAnalyzer(inner, NewMemory(16), "")
+
+	expected := image.Rect(464, 24, 719, 279)
+	for i := 0; i < 2; i++ {
+		r, err := a.FindBestCrop(img, 250, 250)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != expected {
+			t.Fatalf("expected %v, got %v", expected, r)
+		}
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected 1 analysis, got %d", inner.calls)
+	}
+
+	if _, err := a.FindBestCrop(img, 100, 250); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 2 {
+		t.Fatalf("expected a different size to miss the cache, got %d analyses", inner.calls)
+	}
+
+	// a boosted copy must not return the plain result from the cache
+	boosted := a.WithBoosts([]smartcrop.Boost{{Rectangle: image.Rect(0, 0, 250, 250), Weight: 1}})
+	r, err := boosted.FindBestCrop(img, 250, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == expected {
+		t.Fatalf("expected the boost to change the crop, got %v", r)
+	}
+}
+
+func TestAnalyzerOptions(t *testing.T) {
+	// strategies other than the default take no options, which get ignored
+	entropy, err := smartcrop.NewStrategyAnalyzer("entropy", nfnt.NewDefaultResizer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAnalyzer(entropy, NewMemory(16), "entropy")
+	if b := a.WithBoosts([]smartcrop.Boost{{Rectangle: image.Rect(0, 0, 10, 10), Weight: 1}}); b != smartcrop.Analyzer(a) {
+		t.Fatalf("expected the analyzer to be returned unchanged, got %v", b)
+	}
+
+	a = NewAnalyzer(smartcrop.NewAnalyzer(nfnt.NewDefaultResizer()), NewMemory(16), "")
+	keys := map[string]bool{}
+	for _, analyzer := range []smartcrop.Analyzer{
+		a,
+		a.WithDetector(smartcrop.SpectralResidual{}, 1),
+		a.WithDetector(smartcrop.SpectralResidual{}, 2),
+		a.WithDetector(smartcrop.FocusDetector{}, 1),
+		a.WithTextMode(smartcrop.TextKeep),
+		a.WithTextMode(smartcrop.TextExclude),
+	} {
+		c, ok := analyzer.(*Analyzer)
+		if !ok {
+			t.Fatalf("expected a caching analyzer, got %T", analyzer)
+		}
+		keys[c.key(nil, 100, 100, 1)] = true
+	}
+	if len(keys) != 6 {
+		t.Fatalf("expected every option to change the cache key, got %d keys", len(keys))
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(2)
+	m.Set("a", []byte("1"))
+	m.Set("b", []byte("2"))
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	// b is now the least recently used entry
+	m.Set("c", []byte("3"))
+
+	if _, ok := m.Get("b"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if v, ok := m.Get("a"); !ok || string(v) != "1" {
+		t.Fatalf("expected a to be 1, got %q", v)
+	}
+	if m.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", m.Len())
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartcrop-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // temporary directory
+
+	img := decodeGopher(t)
+	inner := &countingAnalyzer{Analyzer: smartcrop.NewAnalyzer(nfnt.NewDefaultResizer())}
+	first, err := NewAnalyzer(inner, Dir(dir), "").FindBestCrops(img, 250, 250, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new Analyzer sharing the directory finds the stored result
+	second, err := NewAnalyzer(inner, Dir(dir), "").FindBestCrops(img, 250, 250, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected 1 analysis, got %d", inner.calls)
+	}
+	if len(first) != len(second) {
+		t.Fatalf("expected %d crops, got %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected crop %d to be %v, got %v", i, first[i], second[i])
+		}
+	}
+}
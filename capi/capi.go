@@ -0,0 +1,122 @@
+//go:build cgo
+// +build cgo
+
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+// Package main builds smartcrop as a C shared library:
+//
+//	go build -buildmode=c-shared -o libsmartcrop.so ./capi
+//
+// See smartcrop.h for the API. smartcrop_strerror is implemented in C, see
+// smartcrop.c.
+package main
+
+// #include "smartcrop.h"
+import "C"
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"unsafe"
+
+	"github.com/muesli/smartcrop"
+	_ "github.com/muesli/smartcrop/internal/codec" // registers the image decoders
+	"github.com/muesli/smartcrop/nfnt"
+)
+
+var analyzer = smartcrop.NewAnalyzer(nfnt.NewDefaultResizer())
+
+//export smartcrop_find_best_crop_rgba
+func smartcrop_find_best_crop_rgba(pixels *C.uint8_t, width, height, stride, cropWidth, cropHeight C.int, out *C.smartcrop_rect) (code C.int) {
+	defer recoverPanic(&code)
+
+	// sizes are computed in 64 bits, as C ints overflow for large images
+	w, h, s := int64(width), int64(height), int64(stride)
+	if pixels == nil || out == nil || w <= 0 || h <= 0 || s < w*4 || s*h > math.MaxInt32 {
+		return C.SMARTCROP_ERR_INVALID_ARGUMENT
+	}
+
+	img := &image.NRGBA{
+		Pix:    C.GoBytes(unsafe.Pointer(pixels), C.int(s*h)),
+		Stride: int(stride),
+		Rect:   image.Rect(0, 0, int(width), int(height)),
+	}
+	return findBestCrop(img, cropWidth, cropHeight, out)
+}
+
+//export smartcrop_find_best_crop_encoded
+func smartcrop_find_best_crop_encoded(data *C.uint8_t, length C.size_t, cropWidth, cropHeight C.int, out *C.smartcrop_rect) (code C.int) {
+	defer recoverPanic(&code)
+
+	if data == nil || out == nil || length == 0 || uint64(length) > math.MaxInt32 {
+		return C.SMARTCROP_ERR_INVALID_ARGUMENT
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(C.GoBytes(unsafe.Pointer(data), C.int(length))))
+	if err != nil {
+		return C.SMARTCROP_ERR_DECODE
+	}
+	return findBestCrop(img, cropWidth, cropHeight, out)
+}
+
+func findBestCrop(img image.Image, cropWidth, cropHeight C.int, out *C.smartcrop_rect) C.int {
+	if cropWidth < 0 || cropHeight < 0 {
+		return C.SMARTCROP_ERR_INVALID_ARGUMENT
+	}
+
+	// like the smartcrop command, fall back to a square crop
+	w, h := int(cropWidth), int(cropHeight)
+	if w == 0 && h == 0 {
+		w, h = img.Bounds().Dx(), img.Bounds().Dy()
+		if w > h {
+			w = h
+		} else {
+			h = w
+		}
+	}
+
+	r, err := analyzer.FindBestCrop(img, w, h)
+	if err != nil {
+		return C.SMARTCROP_ERR_ANALYZE
+	}
+	out.x = C.int(r.Min.X)
+	out.y = C.int(r.Min.Y)
+	out.width = C.int(r.Dx())
+	out.height = C.int(r.Dy())
+	return C.SMARTCROP_OK
+}
+
+// recoverPanic turns a panic, e.g. in a decoder, into an error code, as it
+// would otherwise abort the host process. It must be deferred by every
+// exported function.
+func recoverPanic(code *C.int) {
+	if r := recover(); r != nil {
+		*code = C.SMARTCROP_ERR_INTERNAL
+	}
+}
+
+func main() {}
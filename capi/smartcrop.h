@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+#ifndef SMARTCROP_H
+#define SMARTCROP_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* Error codes returned by the smartcrop functions. */
+#define SMARTCROP_OK 0
+#define SMARTCROP_ERR_INVALID_ARGUMENT 1
+#define SMARTCROP_ERR_DECODE 2
+#define SMARTCROP_ERR_ANALYZE 3
+#define SMARTCROP_ERR_INTERNAL 4
+
+/* A crop rectangle, relative to the top left corner of the image. */
+typedef struct {
+	int x;
+	int y;
+	int width;
+	int height;
+} smartcrop_rect;
+
+/*
+ * Finds the best crop of crop_width x crop_height (or the same aspect ratio)
+ * for an image given as raw, non-premultiplied RGBA pixels, 4 bytes per pixel
+ * and stride bytes per row. The crop is stored in out. Buffers of stride *
+ * height bytes must not exceed 2 GiB.
+ */
+int smartcrop_find_best_crop_rgba(uint8_t* pixels, int width, int height, int stride,
+	int crop_width, int crop_height, smartcrop_rect* out);
+
+/*
+ * Finds the best crop for an encoded JPEG, PNG, GIF, BMP, TIFF or WebP image
+ * of len bytes, which must not exceed 2 GiB. The crop is stored in out.
+ */
+int smartcrop_find_best_crop_encoded(uint8_t* data, size_t len,
+	int crop_width, int crop_height, smartcrop_rect* out);
+
+/* Returns a static description of an error code. */
+const char* smartcrop_strerror(int code);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+/*
+ * Exercises the smartcrop C API: crops an encoded image file and a generated
+ * RGBA buffer with a bright square on a dark background.
+ *
+ *	make test
+ */
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "smartcrop.h"
+
+static int check(const char* what, int code) {
+	if (code != SMARTCROP_OK) {
+		fprintf(stderr, "%s: %s\n", what, smartcrop_strerror(code));
+		return 0;
+	}
+	return 1;
+}
+
+static int test_encoded(const char* filename) {
+	FILE* f = fopen(filename, "rb");
+	if (!f) {
+		perror(filename);
+		return 0;
+	}
+	fseek(f, 0, SEEK_END);
+	long len = ftell(f);
+	fseek(f, 0, SEEK_SET);
+
+	uint8_t* data = malloc(len);
+	if (fread(data, 1, len, f) != (size_t)len) {
+		perror(filename);
+		fclose(f);
+		free(data);
+		return 0;
+	}
+	fclose(f);
+
+	smartcrop_rect r;
+	int ok = check("encoded", smartcrop_find_best_crop_encoded(data, len, 250, 250, &r));
+	free(data);
+	if (!ok) {
+		return 0;
+	}
+	printf("%s: %dx%d+%d+%d\n", filename, r.width, r.height, r.x, r.y);
+
+	if (r.width != r.height) {
+		fprintf(stderr, "encoded: expected a square crop\n");
+		return 0;
+	}
+	return 1;
+}
+
+static int test_rgba(void) {
+	const int width = 300, height = 100, stride = width * 4;
+	uint8_t* pixels = calloc(stride * height, 1);
+
+	/* a colorful square on the right */
+	for (int y = 30; y < 70; y++) {
+		for (int x = 220; x < 260; x++) {
+			uint8_t* p = pixels + y * stride + x * 4;
+			p[0] = 255;
+			p[1] = (x * 7) & 0xff;
+			p[2] = 40;
+			p[3] = 255;
+		}
+	}
+	for (int i = 3; i < stride * height; i += 4) {
+		pixels[i] = 255;
+	}
+
+	smartcrop_rect r;
+	int ok = check("rgba", smartcrop_find_best_crop_rgba(pixels, width, height, stride, 100, 100, &r));
+	free(pixels);
+	if (!ok) {
+		return 0;
+	}
+	printf("rgba: %dx%d+%d+%d\n", r.width, r.height, r.x, r.y);
+
+	if (r.x + r.width < 260 || r.x > 220) {
+		fprintf(stderr, "rgba: expected the crop to contain the square\n");
+		return 0;
+	}
+
+	if (smartcrop_find_best_crop_rgba(NULL, width, height, stride, 100, 100, &r) != SMARTCROP_ERR_INVALID_ARGUMENT) {
+		fprintf(stderr, "rgba: expected an error for a missing buffer\n");
+		return 0;
+	}
+
+	/* sizes overflowing an int must be rejected before touching the buffer */
+	uint8_t pixel[4] = {0};
+	if (smartcrop_find_best_crop_rgba(pixel, 1 << 20, 1 << 20, 1 << 22, 100, 100, &r) != SMARTCROP_ERR_INVALID_ARGUMENT) {
+		fprintf(stderr, "rgba: expected an error for a buffer larger than 2 GiB\n");
+		return 0;
+	}
+	if (smartcrop_find_best_crop_rgba(pixel, 1 << 30, 1, 4, 100, 100, &r) != SMARTCROP_ERR_INVALID_ARGUMENT) {
+		fprintf(stderr, "rgba: expected an error for a stride shorter than a row\n");
+		return 0;
+	}
+	return 1;
+}
+
+int main(int argc, char** argv) {
+	if (argc != 2) {
+		fprintf(stderr, "usage: %s IMAGE\n", argv[0]);
+		return 2;
+	}
+
+	if (!test_encoded(argv[1]) || !test_rgba()) {
+		return 1;
+	}
+	printf("ok\n");
+	return 0;
+}
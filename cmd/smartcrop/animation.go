@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"image"
+	"image/gif"
+	"io"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/nfnt"
+	"github.com/muesli/smartcrop/options"
+)
+
+// animationSamples is the maximum number of frames analyzed per animation.
+const animationSamples = 32
+
+// animation is an animated GIF. As an image.Image it is its first frame, so it
+// can be handled like any other image; only analyzing, cropping and encoding
+// it as GIF take all frames into account.
+type animation struct {
+	image.Image
+	gif *gif.GIF
+}
+
+func newAnimation(g *gif.GIF) animation {
+	return animation{
+		Image: smartcrop.GIFFrames(g, 1)[0],
+		gif:   g,
+	}
+}
+
+// findCrops returns the crops suiting all frames of the animation. Analyzers
+// which can't analyze animations only get to see the first frame.
+func (a animation) findCrops(analyzer smartcrop.Analyzer, width, height, count int) ([]smartcrop.Crop, error) {
+	aa, ok := analyzer.(smartcrop.AnimationAnalyzer)
+	if !ok {
+		r, err := analyzer.FindBestCrop(a.Image, width, height)
+		return []smartcrop.Crop{{Rectangle: r}}, err
+	}
+	frames := smartcrop.GIFFrames(a.gif, animationSamples)
+	return aa.FindBestAnimationCrops(frames, width, height, count)
+}
+
+// crop crops all frames of the animation to r and, if requested, scales them
+// to width x height.
+func (a animation) crop(r image.Rectangle, width, height int, resize bool) animation {
+	var resizer options.Resizer
+	if resize {
+		resizer = nfnt.NewDefaultResizer()
+	}
+	return animation{
+		Image: cropImage(a.Image, r, width, height, resize),
+		gif:   smartcrop.CropGIF(a.gif, r, width, height, resizer),
+	}
+}
+
+// encode writes the animation as GIF.
+func (a animation) encode(w io.Writer) error {
+	return gif.EncodeAll(w, a.gif)
+}
@@ -0,0 +1,307 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/muesli/smartcrop/internal/codec"
+)
+
+const (
+	defaultNameTemplate = "{name}_{size}{ext}"
+	defaultManifestName = ".smartcrop-manifest"
+)
+
+type batch struct {
+	inputDir  string
+	outputDir string
+	name      string
+	workers   int
+	manifest  string
+	opts      cropOptions
+}
+
+// manifestEntry records a successfully processed input file. The size and
+// modification time are stored so that changed inputs get processed again,
+// the options so that all inputs get processed again with different settings.
+type manifestEntry struct {
+	Input   string    `json:"input"`
+	Outputs []string  `json:"outputs"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modtime"`
+	Options string    `json:"options"`
+}
+
+type batchJob struct {
+	path string
+	rel  string
+	info os.FileInfo
+}
+
+type batchFailure struct {
+	path string
+	err  error
+}
+
+func (b batch) run() error {
+	if b.outputDir == "" {
+		return errors.New("No output directory given")
+	}
+	if len(b.opts.sizes) > 1 && !hasSizePlaceholder(b.name) {
+		return errors.New("-name needs a size placeholder like {size} when cropping to multiple sizes")
+	}
+	if b.workers < 1 {
+		b.workers = 1
+	}
+
+	// don't pick up our own results when the output lives inside the input directory
+	absIn, err := filepath.Abs(b.inputDir)
+	if err != nil {
+		return err
+	}
+	absOut, err := filepath.Abs(b.outputDir)
+	if err != nil {
+		return err
+	}
+	if absIn == absOut {
+		return errors.New("-output-dir must differ from -input-dir")
+	}
+
+	if b.manifest == "" {
+		b.manifest = filepath.Join(b.outputDir, defaultManifestName)
+	}
+	if err := os.MkdirAll(b.outputDir, 0755); err != nil {
+		return fmt.Errorf("can't create output directory: %v", err)
+	}
+
+	done, err := readManifest(b.manifest)
+	if err != nil {
+		return fmt.Errorf("can't read manifest: %v", err)
+	}
+	mf, err := os.OpenFile(b.manifest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("can't open manifest: %v", err)
+	}
+	defer mf.Close() //nolint:errcheck // entries are synced after each write
+
+	options := b.options()
+	var jobs []batchJob
+	skipped := 0
+	err = filepath.Walk(b.inputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if abs, err := filepath.Abs(path); err == nil && abs == absOut {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if codec.FormatFromExtension(filepath.Ext(path)) == "" {
+			return nil
+		}
+		rel, err := filepath.Rel(b.inputDir, path)
+		if err != nil {
+			return err
+		}
+		if e, ok := done[rel]; ok && e.Size == info.Size() && e.ModTime.Equal(info.ModTime()) && e.Options == options {
+			skipped++
+			return nil
+		}
+		jobs = append(jobs, batchJob{path: path, rel: rel, info: info})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("can't read input directory: %v", err)
+	}
+
+	var mu sync.Mutex
+	var failures []batchFailure
+	enc := json.NewEncoder(mf)
+
+	jobCh := make(chan batchJob)
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				outputs, err := b.process(j)
+
+				mu.Lock()
+				if err == nil {
+					err = enc.Encode(manifestEntry{
+						Input:   filepath.ToSlash(j.rel),
+						Outputs: outputs,
+						Size:    j.info.Size(),
+						ModTime: j.info.ModTime(),
+						Options: options,
+					})
+					if err == nil {
+						err = mf.Sync()
+					}
+				}
+				if err != nil {
+					failures = append(failures, batchFailure{path: j.path, err: err})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, j := range jobs {
+		jobCh <- j
+	}
+	close(jobCh)
+	wg.Wait()
+
+	fmt.Fprintf(os.Stderr, "%d processed, %d skipped, %d failed\n", len(jobs)-len(failures), skipped, len(failures))
+	if len(failures) == 0 {
+		return nil
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].path < failures[j].path
+	})
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "  %s: %v\n", f.path, f.err)
+	}
+	return fmt.Errorf("%d of %d files failed", len(failures), len(jobs))
+}
+
+// options describes the settings the outputs depend on, so that a run with
+// different settings doesn't skip files processed before.
+func (b batch) options() string {
+	sizes := make([]string, 0, len(b.opts.sizes))
+	for _, s := range b.opts.sizes {
+		sizes = append(sizes, s.String())
+	}
+	return fmt.Sprintf("sizes=%s;name=%s;format=%s;quality=%d;resize=%t;analyzer=%s",
+		strings.Join(sizes, ","), b.name, b.opts.format, b.opts.quality, b.opts.resize, b.opts.analyzerOptions())
+}
+
+// process crops a single file to every requested size and returns the output
+// paths relative to the output directory.
+func (b batch) process(j batchJob) ([]string, error) {
+	img, inFormat, err := decodeFile(j.path)
+	if err != nil {
+		return nil, err
+	}
+
+	// with an explicit format {ext} expands to the extension of that format
+	name := j.rel
+	if b.opts.format != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + codec.Extension(b.opts.format)
+	}
+
+	var outputs []string
+	for _, s := range b.opts.sizes {
+		width, height := s.cropDimensions(img)
+		rel := filepath.Join(filepath.Dir(j.rel), expandName(b.name, name, width, height))
+		out := filepath.Join(b.outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return nil, fmt.Errorf("can't create output directory: %v", err)
+		}
+		format, err := outputFormat(b.opts.format, out, inFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		crops, err := b.opts.findCrops(img, width, height, 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := encodeFile(out, cropImage(img, crops[0].Rectangle, width, height, b.opts.resize), format, b.opts.quality); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, filepath.ToSlash(rel))
+	}
+	return outputs, nil
+}
+
+// expandName fills in the placeholders of an output filename template:
+// {name} is the input filename without its extension, {ext} the extension
+// including the dot, {width} and {height} the crop dimensions and {size} is
+// short for {width}x{height}. Images read from stdin are named "stdin".
+func expandName(tmpl, input string, width, height int) string {
+	if input == "-" {
+		input = "stdin"
+	}
+	base := filepath.Base(input)
+	ext := filepath.Ext(base)
+	w, h := strconv.Itoa(width), strconv.Itoa(height)
+
+	return strings.NewReplacer(
+		"{name}", strings.TrimSuffix(base, ext),
+		"{ext}", ext,
+		"{width}", w,
+		"{height}", h,
+		"{size}", w+"x"+h,
+	).Replace(tmpl)
+}
+
+// hasSizePlaceholder reports whether the filename template tmpl yields
+// different names for different sizes.
+func hasSizePlaceholder(tmpl string) bool {
+	return expandName(tmpl, "", 1, 1) != expandName(tmpl, "", 2, 2)
+}
+
+// readManifest returns the entries of an existing manifest, keyed by their
+// input path. A missing manifest is not an error.
+func readManifest(filename string) (map[string]manifestEntry, error) {
+	entries := make(map[string]manifestEntry)
+
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e manifestEntry
+		// a partially written last line from an interrupted run is ignored,
+		// that file simply gets processed again
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			continue
+		}
+		entries[filepath.FromSlash(e.Input)] = e
+	}
+	return entries, s.Err()
+}
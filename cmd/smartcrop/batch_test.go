@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copyFile copies the file src to dst, creating the directories of dst.
+func copyFile(t *testing.T, src, dst string) {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dst, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartcrop-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // temporary directory
+
+	in := filepath.Join(dir, "in")
+	out := filepath.Join(dir, "out")
+	copyFile(t, "../../examples/gopher.jpg", filepath.Join(in, "a", "gopher.jpg"))
+	if err := ioutil.WriteFile(filepath.Join(in, "notes.txt"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := batch{
+		inputDir:  in,
+		outputDir: out,
+		name:      defaultNameTemplate,
+		workers:   2,
+		opts:      cropOptions{sizes: []size{{width: 100, height: 100}}, resize: true, quality: 85},
+	}
+	if err := b.run(); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(out, "a", "gopher_100x100.jpg")
+	if _, err := os.Stat(output); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := filepath.Join(out, defaultManifestName)
+	entries, err := readManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := entries[filepath.Join("a", "gopher.jpg")]
+	if len(entries) != 1 || !ok {
+		t.Fatalf("expected a manifest entry for a/gopher.jpg, got %v", entries)
+	}
+	if len(e.Outputs) != 1 || e.Outputs[0] != "a/gopher_100x100.jpg" {
+		t.Fatalf("expected the output a/gopher_100x100.jpg, got %v", e.Outputs)
+	}
+
+	// an unchanged file gets skipped
+	if err := os.Remove(output); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be skipped, got %v", output, err)
+	}
+
+	// different settings process it again
+	b.opts.sizes = []size{{width: 50, height: 50}}
+	if err := b.run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a", "gopher_50x50.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = readManifest(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if e := entries[filepath.Join("a", "gopher.jpg")]; e.Outputs[0] != "a/gopher_50x50.jpg" {
+		t.Fatalf("expected the latest entry to win, got %v", e.Outputs)
+	}
+
+	// a truncated last line of an interrupted run is ignored
+	f, err := os.OpenFile(manifest, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"input":"a/go`)
+	_ = f.Close()
+	if entries, err = readManifest(manifest); err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %v (%v)", entries, err)
+	}
+}
+
+func TestBatchFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartcrop-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // temporary directory
+
+	in := filepath.Join(dir, "in")
+	if err := os.MkdirAll(in, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(in, "broken.jpg"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := batch{
+		inputDir:  in,
+		outputDir: filepath.Join(dir, "out"),
+		name:      defaultNameTemplate,
+		opts:      cropOptions{sizes: []size{{width: 100, height: 100}}, resize: true, quality: 85},
+	}
+	if err := b.run(); err == nil {
+		t.Fatal("expected an error for an undecodable file")
+	}
+	entries, err := readManifest(filepath.Join(dir, "out", defaultManifestName))
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected no manifest entries, got %v (%v)", entries, err)
+	}
+
+	b.outputDir = in
+	if err := b.run(); err == nil {
+		t.Fatal("expected an error when writing into the input directory")
+	}
+}
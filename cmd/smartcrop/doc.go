@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+/*
+Smartcrop crops images to the most interesting region of the requested size.
+
+Usage:
+
+	smartcrop [flags] [input]
+	smartcrop serve [flags]
+	smartcrop worker [flags]
+
+Run any of them with -help to list their flags.
+
+# Cropping
+
+The input can be given with -input or as argument, the output with -output.
+Passing - reads the image from stdin or writes it to stdout, keeping the format
+of the input:
+
+	smartcrop -input examples/gopher.jpg -output gopher_cropped.jpg -width 300 -height 150
+	curl -s https://example.com/image.jpg | smartcrop -width 300 -height 150 -output - - > cropped.jpg
+
+JPEG, PNG, GIF, BMP, TIFF and WebP images can be read; all formats but WebP
+can be written. The output format is taken from -format or, if not given, from
+the extension of the output filename. Animated GIFs are cropped as a whole to a
+region suiting up to 32 evenly spaced frames; other output formats than GIF
+store the first frame only.
+
+-size, -aspect and -preset request several renditions at once and may be
+combined. -aspect crops the largest area with the given aspect ratio without
+resizing it, -preset crops to a well-known format like og-image, and
+-list-presets shows the presets. The output filename may contain the
+placeholders {name}, {ext}, {width}, {height} and {size}:
+
+	smartcrop -input examples/gopher.jpg -output "gopher_{size}.jpg" -size 300x150,150x150 -aspect 16:9
+
+# Hints
+
+-boost marks regions as important, e.g. faces found by a separate detector,
+-avoid marks regions like watermarks which should rather stay out of the crop.
+Both take a rectangle as x,y,width,height with an optional :weight and may be
+repeated. -hints reads the same regions from a JSON file:
+
+	{
+	  "boost": [{ "x": 420, "y": 80, "width": 120, "height": 150, "weight": 1.0 }],
+	  "avoid": [{ "x": 0, "y": 560, "width": 200, "height": 40 }]
+	}
+
+-saliency, -color-contrast and -focus enable additional detectors with the
+given weight; their share of the score is reported as "extra" in the JSON
+output. -text keep makes sure text blocks like captions end up in the crop as a
+whole, -text exclude keeps them out of it.
+
+-strategy selects one of the strategies of libvips instead of the default
+"smart" analysis. They always return the largest crop with the requested
+aspect ratio and can't be combined with hints, detectors or -text.
+
+# Other outputs
+
+-json prints the chosen crop instead of writing an image, one object per size,
+with the crop in pixels and normalized to the range of 0 to 1, the dimensions
+of the source image, the requested size and the scores of the crop.
+-focal-point prints the focal point of the image and a rectangle around its
+important content.
+
+-kenburns renders a pan-and-zoom of the given number of frames from the best
+crop to another area of interest, or zooming out if there is none. GIF output is written as a single animation
+playing at -fps frames per second, other formats as a sequence of images
+numbered by the {frame} placeholder.
+
+-debug-output writes the source image with the importance map of the chosen
+crop overlaid: green areas count towards the score, red areas against it. The
+crop is outlined in cyan, -debug-candidates runner-up crops in yellow.
+
+# Batch mode
+
+-input-dir crops all images in a directory tree into -output-dir, mirroring
+the structure of the input directory and naming the files after the -name
+template. Processed files are recorded in a manifest, so running the same
+command again skips the files which haven't changed since and an interrupted
+run can simply be restarted. Changing the sizes, the name template, the output
+format or the analyzer options processes all files again. Failed files are
+listed at the end and cause a non-zero exit code.
+
+-cache-dir stores the crops found for an image and reuses them when the same
+image gets cropped to the same size again. Entries are keyed by a hash of the
+decoded image.
+
+# Worker mode
+
+smartcrop worker keeps running and processes jobs read from stdin, one JSON
+object per line, saving the startup cost of a process per image:
+
+	{"id": 1, "input": "photo.jpg", "sizes": ["300x150", "16:9"], "output": "thumbs/{name}_{size}{ext}"}
+
+Besides input, jobs may set sizes in the syntax of -size, -aspect or -preset,
+an output filename template, format, quality, resize and hints in the format of
+the -hints file. Without an output template only the crops are determined. For
+every job one line gets written to stdout with its id, its zero-based line
+number seq and, per size, the crop as printed by -json plus the output
+filename. Failed jobs carry an error instead and don't stop the worker. Results
+are written as soon as they are done, unless -ordered is given.
+
+# Server mode
+
+smartcrop serve runs the handler of package httpcrop, which crops uploaded
+images and those found in the -root directory on the fly:
+
+	smartcrop serve -listen :8080 -root ./images
+	curl "http://localhost:8080/gopher.jpg?width=300&height=150" > cropped.jpg
+
+-proxy-urls serves the thumbor and imgproxy style URLs of package urlcrop
+instead, verifying signatures if -thumbor-key or -imgproxy-key and
+-imgproxy-salt are set. -iiif serves the IIIF Image API of package iiif for the
+-root directory.
+*/
+package main
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/muesli/smartcrop/internal/codec"
+)
+
+// parseFormat normalizes a format name given by the user. An empty name is
+// returned unchanged.
+func parseFormat(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	format := codec.FormatFromExtension("." + name)
+	if format == "" {
+		return "", fmt.Errorf("unknown image format %q", name)
+	}
+	if !codec.CanEncode(format) {
+		return "", fmt.Errorf("can't encode %s images", format)
+	}
+	return format, nil
+}
+
+// outputFormat picks the format an image gets written in: an explicitly
+// requested format wins, otherwise it is derived from the extension of the
+// output filename. If neither is set, e.g. when writing to stdout, the format
+// of the input image is kept.
+func outputFormat(format, filename, inputFormat string) (string, error) {
+	if format == "" && filename != "-" {
+		if ext := strings.ToLower(filepath.Ext(filename)); ext != "" {
+			if format = codec.FormatFromExtension(ext); format == "" {
+				return "", fmt.Errorf("unknown image format for extension %s, choose one with -format", ext)
+			}
+		}
+	}
+	if format == "" {
+		format = inputFormat
+	}
+	if !codec.CanEncode(format) {
+		return "", fmt.Errorf("can't encode %s images, choose another format with -format", format)
+	}
+	return format, nil
+}
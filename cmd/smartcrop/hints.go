@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/muesli/smartcrop"
+)
+
+// hints contains regions of an image which should preferably be kept in or
+// left out of the crop, e.g. faces or watermarks found by other tools.
+type hints struct {
+	Boost []hintRegion `json:"boost"`
+	Avoid []hintRegion `json:"avoid"`
+}
+
+// hintRegion is a region of an image. A missing weight defaults to 1.
+type hintRegion struct {
+	X      int      `json:"x"`
+	Y      int      `json:"y"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Weight *float64 `json:"weight,omitempty"`
+}
+
+func (r hintRegion) boost(sign float64) smartcrop.Boost {
+	weight := 1.0
+	if r.Weight != nil {
+		weight = math.Abs(*r.Weight)
+	}
+	return smartcrop.Boost{
+		Rectangle: image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height),
+		Weight:    sign * weight,
+	}
+}
+
+// boosts returns the hints as boosts for the analyzer. Regions to avoid get a
+// negative weight.
+func (h hints) boosts() []smartcrop.Boost {
+	var boosts []smartcrop.Boost
+	for _, r := range h.Boost {
+		boosts = append(boosts, r.boost(1))
+	}
+	for _, r := range h.Avoid {
+		boosts = append(boosts, r.boost(-1))
+	}
+	return boosts
+}
+
+func readHints(filename string) (hints, error) {
+	var h hints
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return h, fmt.Errorf("can't open hints file: %v", err)
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+
+	if err := json.NewDecoder(f).Decode(&h); err != nil {
+		return h, fmt.Errorf("can't parse hints file: %v", err)
+	}
+	return h, nil
+}
+
+// parseBoost parses a region given as x,y,w,h with an optional :weight suffix.
+// The sign of the weight is replaced by sign.
+func parseBoost(s string, sign float64) (smartcrop.Boost, error) {
+	var r hintRegion
+
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+	if len(parts) == 2 {
+		w, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return smartcrop.Boost{}, fmt.Errorf("invalid weight in region %q", s)
+		}
+		r.Weight = &w
+	}
+
+	coords := strings.Split(parts[0], ",")
+	if len(coords) != 4 {
+		return smartcrop.Boost{}, fmt.Errorf("invalid region %q, expected x,y,w,h[:weight]", s)
+	}
+	v := make([]int, len(coords))
+	for i, c := range coords {
+		n, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || (i >= 2 && n <= 0) {
+			return smartcrop.Boost{}, fmt.Errorf("invalid region %q, expected x,y,w,h[:weight]", s)
+		}
+		v[i] = n
+	}
+	r.X, r.Y, r.Width, r.Height = v[0], v[1], v[2], v[3]
+
+	return r.boost(sign), nil
+}
+
+// boostFlag is a flag.Value collecting boosts from repeated flags. sign
+// decides whether the regions get boosted or avoided.
+type boostFlag struct {
+	boosts *[]smartcrop.Boost
+	sign   float64
+}
+
+func (f boostFlag) String() string {
+	if f.boosts == nil {
+		return ""
+	}
+	var s []string
+	for _, b := range *f.boosts {
+		if (b.Weight < 0) == (f.sign < 0) {
+			s = append(s, fmt.Sprintf("%d,%d,%d,%d:%g", b.Min.X, b.Min.Y, b.Dx(), b.Dy(), math.Abs(b.Weight)))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func (f boostFlag) Set(value string) error {
+	b, err := parseBoost(value, f.sign)
+	if err != nil {
+		return err
+	}
+	*f.boosts = append(*f.boosts, b)
+	return nil
+}
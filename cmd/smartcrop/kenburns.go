@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"strconv"
+	"strings"
+
+	"github.com/muesli/smartcrop"
+)
+
+// kenBurnsFile renders a pan-and-zoom of frames frames across the image stored
+// at input. GIF output gets written as a single animation, other formats as a
+// sequence of files whose names need to contain a {frame} placeholder.
+func kenBurnsFile(input, output string, frames, fps int, opts cropOptions) error {
+	if len(opts.sizes) > 1 {
+		return errors.New("can't render a pan-and-zoom in multiple sizes")
+	}
+	if fps < 1 {
+		return errors.New("-fps must be at least 1")
+	}
+
+	img, inFormat, err := decodeFile(input)
+	if err != nil {
+		return err
+	}
+	if a, ok := img.(animation); ok {
+		img = a.Image
+	}
+
+	width, height := opts.sizes[0].cropDimensions(img)
+	output = expandName(output, input, width, height)
+	format, err := outputFormat(opts.format, strings.Replace(output, "{frame}", "", -1), inFormat)
+	if err != nil {
+		return err
+	}
+	if format != "gif" && !strings.Contains(output, "{frame}") {
+		return errors.New("output filename needs a {frame} placeholder to write a sequence of images")
+	}
+
+	analyzer, ok := opts.analyzer().(smartcrop.KenBurnsAnalyzer)
+	if !ok {
+		return fmt.Errorf("the %s strategy can't render a pan-and-zoom", opts.strategy)
+	}
+	k, err := analyzer.FindKenBurns(img, width, height)
+	if err != nil {
+		return fmt.Errorf("can't find a pan-and-zoom path: %v", err)
+	}
+
+	g := &gif.GIF{}
+	digits := len(strconv.Itoa(frames - 1))
+	for i := 0; i < frames; i++ {
+		t := 0.0
+		if frames > 1 {
+			t = float64(i) / float64(frames-1)
+		}
+		frame := cropImage(img, k.Frame(t), width, height, true)
+
+		if format == "gif" {
+			p := image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9)
+			draw.FloydSteinberg.Draw(p, p.Bounds(), frame, frame.Bounds().Min)
+			g.Image = append(g.Image, p)
+			g.Delay = append(g.Delay, 100/fps)
+			continue
+		}
+
+		out := strings.Replace(output, "{frame}", fmt.Sprintf("%0*d", digits, i), -1)
+		if err := encodeFile(out, frame, format, opts.quality); err != nil {
+			return err
+		}
+	}
+	if format != "gif" {
+		return nil
+	}
+	return encodeFile(output, animation{Image: g.Image[0], gif: g}, format, opts.quality)
+}
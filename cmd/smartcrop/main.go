@@ -29,91 +29,464 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
+	"image/gif"
 	"io"
+	"io/ioutil"
 	"os"
+	"runtime"
+	"strings"
 
 	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/cache"
+	"github.com/muesli/smartcrop/internal/codec"
 	"github.com/muesli/smartcrop/nfnt"
 )
 
 func main() {
-	input := flag.String("input", "", "input filename")
-	output := flag.String("output", "", "output filename")
+	if len(os.Args) > 1 {
+		var cmd func([]string) error
+		switch os.Args[1] {
+		case "serve":
+			cmd = serve
+		case "worker":
+			cmd = worker
+		}
+		if cmd != nil {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	input := flag.String("input", "", "input filename, - reads from stdin (can also be passed as argument)")
+	output := flag.String("output", "", "output filename, may contain the placeholders of -name")
+	inputDir := flag.String("input-dir", "", "input directory, processed recursively (batch mode)")
+	outputDir := flag.String("output-dir", "", "output directory (batch mode)")
+	name := flag.String("name", defaultNameTemplate, "output filename template (batch mode)")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of parallel workers (batch mode)")
+	manifest := flag.String("manifest", "", "manifest of processed files, used to resume (batch mode, default <output-dir>/"+defaultManifestName+")")
 	w := flag.Int("width", 0, "crop width")
 	h := flag.Int("height", 0, "crop height")
+	var sizes []size
+	flag.Var(sizeFlag{&sizes, parseSize}, "size", "crop size as WxH, may be repeated or a comma-separated list")
+	flag.Var(sizeFlag{&sizes, parseAspect}, "aspect", "crop the largest area with aspect ratio W:H, may be repeated or a comma-separated list")
+	flag.Var(sizeFlag{&sizes, parsePreset}, "preset", "crop to the size of a preset, may be repeated or a comma-separated list")
+	presetList := flag.Bool("list-presets", false, "list the available presets")
+	var boosts []smartcrop.Boost
+	flag.Var(boostFlag{&boosts, 1}, "boost", "region to keep in the crop as x,y,w,h[:weight], may be repeated")
+	flag.Var(boostFlag{&boosts, -1}, "avoid", "region to keep out of the crop as x,y,w,h[:weight], may be repeated")
+	hintsFile := flag.String("hints", "", "JSON file with regions to boost and avoid")
+	cacheDir := flag.String("cache-dir", "", "directory to cache analysis results in, reused for identical images")
+	saliency := flag.Float64("saliency", 0, "weight of the spectral residual saliency detector, 0 disables it")
+	colorContrast := flag.Float64("color-contrast", 0, "weight of the colour contrast detector, 0 disables it")
+	focus := flag.Float64("focus", 0, "weight of the focus detector favouring sharp regions over blurred ones, 0 disables it")
+	strategy := flag.String("strategy", "smart", "cropping strategy: "+strings.Join(smartcrop.Strategies(), ", "))
+	text := flag.String("text", "", "keep text blocks like captions whole inside the crop (keep) or out of it (exclude)")
 	resize := flag.Bool("resize", true, "resize after cropping")
 	quality := flag.Int("quality", 85, "jpeg quality")
+	format := flag.String("format", "", "output format: jpeg, png, gif, bmp or tiff (default derived from the output filename or input)")
+	debugOutput := flag.String("debug-output", "", "write the source image with the chosen crop and its importance map overlaid to this file")
+	debugCandidates := flag.Int("debug-candidates", 0, "number of runner-up crops outlined in the debug output")
+	jsonOut := flag.Bool("json", false, "print the crop rectangle as JSON instead of writing an image")
+	focalPoint := flag.Bool("focal-point", false, "print the focal point and a rectangle around the important content as JSON instead of writing an image")
+	kenBurns := flag.Int("kenburns", 0, "render a pan-and-zoom of this many frames as animated GIF or, with a {frame} placeholder in the output filename, as a sequence of images")
+	fps := flag.Int("fps", 25, "frames per second of pan-and-zoom GIFs")
 	flag.Parse()
 
+	if *presetList {
+		listPresets(os.Stdout)
+		return
+	}
+
+	if len(sizes) == 0 {
+		sizes = []size{{width: *w, height: *h}}
+	} else if *w != 0 || *h != 0 {
+		fmt.Fprintln(os.Stderr, "-size, -aspect and -preset can't be combined with -width and -height")
+		os.Exit(1)
+	}
+
+	outFormat, err := parseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *hintsFile != "" {
+		h, err := readHints(*hintsFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		boosts = append(boosts, h.boosts()...)
+	}
+
+	textMode, ok := textModes[*text]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "invalid text mode %q, expected keep or exclude\n", *text)
+		os.Exit(1)
+	}
+
+	var detectors []detector
+	if *saliency != 0 {
+		detectors = append(detectors, detector{"saliency", smartcrop.SpectralResidual{}, *saliency})
+	}
+	if *colorContrast != 0 {
+		detectors = append(detectors, detector{"color-contrast", smartcrop.ColorContrast{}, *colorContrast})
+	}
+	if *focus != 0 {
+		detectors = append(detectors, detector{"focus", smartcrop.FocusDetector{}, *focus})
+	}
+
+	if _, err := smartcrop.NewStrategyAnalyzer(*strategy, nfnt.NewDefaultResizer()); err != nil {
+		fmt.Fprintf(os.Stderr, "unknown strategy %q, expected one of %s\n", *strategy, strings.Join(smartcrop.Strategies(), ", "))
+		os.Exit(1)
+	}
+	if *strategy != "smart" && (len(boosts) > 0 || len(detectors) > 0 || textMode != smartcrop.TextIgnore || *kenBurns > 0 || *focalPoint) {
+		fmt.Fprintf(os.Stderr, "the %s strategy can't be combined with hints, detectors, -text, -kenburns or -focal-point\n", *strategy)
+		os.Exit(1)
+	}
+
+	opts := cropOptions{
+		sizes:   sizes,
+		resize:  *resize,
+		quality: *quality,
+		format:  outFormat,
+		boosts:  boosts,
+
+		strategy:  *strategy,
+		detectors: detectors,
+		textMode:  textMode,
+		cacheDir:  *cacheDir,
+
+		debugOutput:     *debugOutput,
+		debugCandidates: *debugCandidates,
+	}
+
+	if *inputDir != "" {
+		if *jsonOut || *debugOutput != "" || *kenBurns > 0 || *focalPoint {
+			fmt.Fprintln(os.Stderr, "-json, -debug-output, -kenburns and -focal-point are not supported in batch mode")
+			os.Exit(1)
+		}
+		b := batch{
+			inputDir:  *inputDir,
+			outputDir: *outputDir,
+			name:      *name,
+			workers:   *workers,
+			manifest:  *manifest,
+			opts:      opts,
+		}
+		if err := b.run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *input == "" && flag.NArg() > 0 {
+		*input = flag.Arg(0)
+	}
 	if *input == "" {
 		fmt.Fprintln(os.Stderr, "No input file given")
 		os.Exit(1)
 	}
 
-	f, err := os.Open(*input)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "can't open input file: %v\n", err)
+	if *kenBurns > 0 {
+		if *jsonOut || *focalPoint {
+			fmt.Fprintln(os.Stderr, "-json and -focal-point can't be combined with -kenburns")
+			os.Exit(1)
+		}
+		if *cacheDir != "" || *debugOutput != "" || !*resize {
+			fmt.Fprintln(os.Stderr, "-cache-dir, -debug-output and -resize=false are not supported with -kenburns")
+			os.Exit(1)
+		}
+		if err := kenBurnsFile(*input, *output, *kenBurns, *fps, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *focalPoint {
+		if *jsonOut || *cacheDir != "" || *debugOutput != "" {
+			fmt.Fprintln(os.Stderr, "-json, -cache-dir and -debug-output are not supported with -focal-point")
+			os.Exit(1)
+		}
+		if err := printFocalPoint(os.Stdout, *input, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *jsonOut {
+		if err := printCrop(os.Stdout, *input, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := cropFile(*input, *output, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	defer f.Close() //nolint:errcheck // read-only file
+}
 
-	img, format, err := image.Decode(f)
+type cropOptions struct {
+	sizes   []size
+	resize  bool
+	quality int
+	format  string
+
+	boosts []smartcrop.Boost
+
+	strategy  string
+	detectors []detector
+	textMode  smartcrop.TextMode
+	cacheDir  string
+
+	debugOutput     string
+	debugCandidates int
+}
+
+// cropFile crops the image stored at input to every requested size. The
+// output filename is a template, see expandName. An output of "-" writes to
+// stdout.
+func cropFile(input, output string, opts cropOptions) error {
+	if len(opts.sizes) > 1 {
+		if output == "-" {
+			return errors.New("can't write multiple sizes to stdout")
+		}
+		if !hasSizePlaceholder(output) {
+			return errors.New("output filename needs a size placeholder like {size} when cropping to multiple sizes")
+		}
+		if opts.debugOutput != "" && !hasSizePlaceholder(opts.debugOutput) {
+			return errors.New("debug output filename needs a size placeholder like {size} when cropping to multiple sizes")
+		}
+	}
+
+	img, inFormat, err := decodeFile(input)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "can't decode input file: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+
+	for _, s := range opts.sizes {
+		width, height := s.cropDimensions(img)
+		out := output
+		if out != "-" {
+			out = expandName(output, input, width, height)
+		}
+		format, err := outputFormat(opts.format, out, inFormat)
+		if err != nil {
+			return err
+		}
+
+		crops, err := opts.findCrops(img, width, height, 1+opts.debugCandidates)
+		if err != nil {
+			return err
+		}
+		if err := writeDebugImage(input, img, crops, width, height, opts); err != nil {
+			return err
+		}
+		if err := encodeFile(out, cropImage(img, crops[0].Rectangle, width, height, opts.resize), format, opts.quality); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeFile decodes the image stored in filename, or read from stdin if
+// filename is "-". The format gets detected from the image data. Animated GIFs
+// are returned as animation.
+func decodeFile(filename string) (image.Image, string, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, "", fmt.Errorf("can't open input file: %v", err)
+		}
+		defer f.Close() //nolint:errcheck // read-only file
+		r = f
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't read input file: %v", err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", fmt.Errorf("can't decode input file: %v", err)
+	}
+
+	if format == "gif" {
+		g, err := gif.DecodeAll(bytes.NewReader(b))
+		if err != nil {
+			return nil, "", fmt.Errorf("can't decode input file: %v", err)
+		}
+		if len(g.Image) > 1 {
+			return newAnimation(g), format, nil
+		}
+	}
+	return img, format, nil
+}
+
+// encodeFile writes img in the given format, see codec.Encode.
+func encodeFile(filename string, img image.Image, format string, quality int) error {
+	if !codec.CanEncode(format) {
+		return fmt.Errorf("can't encode %s images", format)
 	}
 
-	out := *output
 	var fOut io.WriteCloser
-	if out == "-" {
+	var err error
+	if filename == "-" {
 		fOut = os.Stdout
 	} else {
-		fOut, err = os.Create(out)
+		fOut, err = os.Create(filename)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "can't create output file: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("can't create output file: %v", err)
 		}
 	}
 
-	img = crop(img, *w, *h, *resize)
-	switch format {
-	case "png":
-		err = png.Encode(fOut, img)
-	case "jpeg":
-		err = jpeg.Encode(fOut, img, &jpeg.Options{Quality: *quality})
+	if a, ok := img.(animation); ok && format == "gif" {
+		err = a.encode(fOut)
+	} else if ok {
+		err = codec.Encode(fOut, a.Image, format, quality)
+	} else {
+		err = codec.Encode(fOut, img, format, quality)
 	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "can't encode image: %v\n", err)
-		os.Exit(1)
+		_ = fOut.Close()
+		return fmt.Errorf("can't encode image: %v", err)
 	}
 
 	if err := fOut.Close(); err != nil {
-		fmt.Fprintf(os.Stderr, "can't create output file: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("can't create output file: %v", err)
 	}
+	return nil
 }
 
-func crop(img image.Image, w, h int, resize bool) image.Image {
-	width, height := getCropDimensions(img, w, h)
-	resizer := nfnt.NewDefaultResizer()
-	analyzer := smartcrop.NewAnalyzer(resizer)
-	topCrop, _ := analyzer.FindBestCrop(img, width, height)
+// findCrops returns up to count of the best crops for the given dimensions,
+// best first. Unless an error is returned, there's always at least one crop.
+func (opts cropOptions) findCrops(img image.Image, width, height, count int) ([]smartcrop.Crop, error) {
+	analyzer := opts.analyzer()
+	if opts.cacheDir != "" {
+		analyzer = cache.NewAnalyzer(analyzer, cache.Dir(opts.cacheDir), opts.analyzerOptions())
+	}
+
+	var crops []smartcrop.Crop
+	var err error
+	if a, ok := img.(animation); ok {
+		crops, err = a.findCrops(analyzer, width, height, count)
+	} else if ca, ok := analyzer.(smartcrop.CropsAnalyzer); ok {
+		crops, err = ca.FindBestCrops(img, width, height, count)
+	} else {
+		var r image.Rectangle
+		r, err = analyzer.FindBestCrop(img, width, height)
+		crops = []smartcrop.Crop{{Rectangle: r}}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't find crops: %v", err)
+	}
+	if len(crops) == 0 {
+		return nil, errors.New("can't find crops")
+	}
+	return crops, nil
+}
+
+// textModes maps the values of -text to text modes.
+var textModes = map[string]smartcrop.TextMode{
+	"":        smartcrop.TextIgnore,
+	"keep":    smartcrop.TextKeep,
+	"exclude": smartcrop.TextExclude,
+}
+
+// detector is an additional detector enabled on the command line.
+type detector struct {
+	name     string
+	detector smartcrop.Detector
+	weight   float64
+}
+
+// analyzer returns the analyzer configured by the options. Strategies other
+// than the default don't take hints, detectors or text modes.
+func (opts cropOptions) analyzer() smartcrop.Analyzer {
+	if opts.strategy != "" && opts.strategy != "smart" {
+		if analyzer, err := smartcrop.NewStrategyAnalyzer(opts.strategy, nfnt.NewDefaultResizer()); err == nil {
+			return analyzer
+		}
+	}
+
+	analyzer := smartcrop.NewAnalyzer(nfnt.NewDefaultResizer())
+	for _, d := range opts.detectors {
+		analyzer = analyzer.(smartcrop.DetectorAnalyzer).WithDetector(d.detector, d.weight)
+	}
+	if opts.textMode != smartcrop.TextIgnore {
+		analyzer = analyzer.(smartcrop.TextAnalyzer).WithTextMode(opts.textMode)
+	}
+	if len(opts.boosts) > 0 {
+		analyzer = analyzer.(smartcrop.BoostAnalyzer).WithBoosts(opts.boosts)
+	}
+	return analyzer
+}
+
+// analyzerOptions describes the configuration of the analyzer, so cached
+// results of differently configured analyzers don't get mixed up.
+func (opts cropOptions) analyzerOptions() string {
+	var parts []string
+	if opts.strategy != "" && opts.strategy != "smart" {
+		parts = append(parts, "strategy="+opts.strategy)
+	}
+	for _, d := range opts.detectors {
+		parts = append(parts, fmt.Sprintf("%s=%g", d.name, d.weight))
+	}
+	if opts.textMode != smartcrop.TextIgnore {
+		parts = append(parts, fmt.Sprintf("text=%d", opts.textMode))
+	}
+	for _, b := range opts.boosts {
+		parts = append(parts, fmt.Sprintf("boost=%v:%g", b.Rectangle, b.Weight))
+	}
+	return strings.Join(parts, ",")
+}
+
+// cropImage cuts r out of img and, if requested, scales it to width x height.
+// Animations get cropped frame by frame.
+func cropImage(img image.Image, r image.Rectangle, width, height int, resize bool) image.Image {
+	if a, ok := img.(animation); ok {
+		return a.crop(r, width, height, resize)
+	}
 
 	type SubImager interface {
 		SubImage(r image.Rectangle) image.Image
 	}
-	img = img.(SubImager).SubImage(topCrop)
+	img = img.(SubImager).SubImage(r)
 	if resize && (img.Bounds().Dx() != width || img.Bounds().Dy() != height) {
-		img = resizer.Resize(img, uint(width), uint(height))
+		img = nfnt.NewDefaultResizer().Resize(img, uint(width), uint(height))
 	}
 	return img
 }
 
+// writeDebugImage writes the debug overlay for crops, if requested. The first
+// crop is the chosen one, the rest are runner-ups.
+func writeDebugImage(input string, img image.Image, crops []smartcrop.Crop, width, height int, opts cropOptions) error {
+	if opts.debugOutput == "" {
+		return nil
+	}
+
+	out := expandName(opts.debugOutput, input, width, height)
+	format, err := outputFormat("", out, "png")
+	if err != nil {
+		return err
+	}
+	return encodeFile(out, smartcrop.DebugImage(img, crops[0], crops[1:]), format, opts.quality)
+}
+
 func getCropDimensions(img image.Image, width, height int) (int, int) {
 	// if we don't have width or height set use the smaller image dimension as both width and height
 	if width == 0 && height == 0 {
@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"image"
+	"testing"
+
+	"github.com/muesli/smartcrop"
+)
+
+func TestParseSizeSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected size
+		err      bool
+	}{
+		{"300x200", size{width: 300, height: 200}, false},
+		{" 300x0 ", size{width: 300}, false},
+		{"0x0", size{}, false},
+		{"16:9", size{width: 16, height: 9, aspect: true}, false},
+		{"og-image", size{width: 1200, height: 630}, false},
+		{"300", size{}, true},
+		{"300x", size{}, true},
+		{"-1x100", size{}, true},
+		{"300x200x100", size{}, true},
+		{"16:0", size{}, true},
+		{"0:9", size{}, true},
+		{"no-such-preset", size{}, true},
+	}
+
+	for _, test := range tests {
+		s, err := parseSizeSpec(test.spec)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", test.spec, s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+			continue
+		}
+		if s != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.spec, test.expected, s)
+		}
+	}
+}
+
+func TestSizeFlag(t *testing.T) {
+	var sizes []size
+	f := sizeFlag{sizes: &sizes, parse: parseSize}
+	if err := f.Set("100x100,200x50"); err != nil {
+		t.Fatal(err)
+	}
+	if err := (sizeFlag{sizes: &sizes, parse: parseAspect}).Set("4:3"); err != nil {
+		t.Fatal(err)
+	}
+	if s := f.String(); s != "100x100,200x50,4:3" {
+		t.Fatalf("expected 100x100,200x50,4:3, got %s", s)
+	}
+	if err := f.Set("100x100,bad"); err == nil {
+		t.Fatal("expected an error for an invalid size")
+	}
+}
+
+func TestCropDimensions(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 900, 300))
+
+	tests := []struct {
+		size          size
+		width, height int
+	}{
+		{size{width: 300, height: 200}, 300, 200},
+		{size{width: 16, height: 9, aspect: true}, 533, 300},
+		{size{width: 1, height: 1, aspect: true}, 300, 300},
+		{size{width: 1, height: 2, aspect: true}, 150, 300},
+	}
+
+	for _, test := range tests {
+		w, h := test.size.cropDimensions(img)
+		if w != test.width || h != test.height {
+			t.Errorf("%v: expected %dx%d, got %dx%d", test.size, test.width, test.height, w, h)
+		}
+	}
+}
+
+func TestParseBoost(t *testing.T) {
+	tests := []struct {
+		region   string
+		sign     float64
+		expected smartcrop.Boost
+		err      bool
+	}{
+		{"10,20,30,40", 1, smartcrop.Boost{Rectangle: image.Rect(10, 20, 40, 60), Weight: 1}, false},
+		{"10,20,30,40:0.5", 1, smartcrop.Boost{Rectangle: image.Rect(10, 20, 40, 60), Weight: 0.5}, false},
+		{"10,20,30,40:2", -1, smartcrop.Boost{Rectangle: image.Rect(10, 20, 40, 60), Weight: -2}, false},
+		{"10, 20, 30, 40:-2", 1, smartcrop.Boost{Rectangle: image.Rect(10, 20, 40, 60), Weight: 2}, false},
+		{"10,20,30", 1, smartcrop.Boost{}, true},
+		{"10,20,0,40", 1, smartcrop.Boost{}, true},
+		{"10,20,30,x", 1, smartcrop.Boost{}, true},
+		{"10,20,30,40:heavy", 1, smartcrop.Boost{}, true},
+	}
+
+	for _, test := range tests {
+		b, err := parseBoost(test.region, test.sign)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", test.region, b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.region, err)
+			continue
+		}
+		if b != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.region, test.expected, b)
+		}
+	}
+}
+
+func TestBoostFlag(t *testing.T) {
+	var boosts []smartcrop.Boost
+	boost := boostFlag{boosts: &boosts, sign: 1}
+	avoid := boostFlag{boosts: &boosts, sign: -1}
+	if err := boost.Set("10,20,30,40"); err != nil {
+		t.Fatal(err)
+	}
+	if err := avoid.Set("0,0,5,5:0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if s := boost.String(); s != "10,20,30,40:1" {
+		t.Errorf("expected 10,20,30,40:1, got %s", s)
+	}
+	if s := avoid.String(); s != "0,0,5,5:0.5" {
+		t.Errorf("expected 0,0,5,5:0.5, got %s", s)
+	}
+}
+
+func TestExpandName(t *testing.T) {
+	tests := []struct {
+		tmpl, input string
+		expected    string
+		sized       bool
+	}{
+		{"{name}_{size}{ext}", "photos/cat.jpg", "cat_300x200.jpg", true},
+		{"out/{name}-{width}-{height}.png", "cat.tar.gz", "out/cat.tar-300-200.png", true},
+		{"{name}_{width}{ext}", "cat.jpg", "cat_300.jpg", true},
+		{"thumb{ext}", "cat.jpg", "thumb.jpg", false},
+		{"{name}.png", "-", "stdin.png", false},
+	}
+
+	for _, test := range tests {
+		if name := expandName(test.tmpl, test.input, 300, 200); name != test.expected {
+			t.Errorf("%s with %s: expected %s, got %s", test.tmpl, test.input, test.expected, name)
+		}
+		if sized := hasSizePlaceholder(test.tmpl); sized != test.sized {
+			t.Errorf("%s: expected a size placeholder to be %t, got %t", test.tmpl, test.sized, sized)
+		}
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	tests := []struct {
+		format, filename, input string
+		expected                string
+		err                     bool
+	}{
+		{"", "out.png", "jpeg", "png", false},
+		{"", "out.JPG", "png", "jpeg", false},
+		{"png", "out.jpg", "jpeg", "png", false},
+		{"", "-", "gif", "gif", false},
+		{"", "out", "bmp", "bmp", false},
+		{"", "out.xyz", "jpeg", "", true},
+		{"", "-", "webp", "", true},
+	}
+
+	for _, test := range tests {
+		format, err := outputFormat(test.format, test.filename, test.input)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q, %q, %q: expected an error, got %s", test.format, test.filename, test.input, format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q, %q, %q: %v", test.format, test.filename, test.input, err)
+			continue
+		}
+		if format != test.expected {
+			t.Errorf("%q, %q, %q: expected %s, got %s", test.format, test.filename, test.input, test.expected, format)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name, expected string
+		err            bool
+	}{
+		{"", "", false},
+		{"jpg", "jpeg", false},
+		{"png", "png", false},
+		{"tiff", "tiff", false},
+		{"webp", "", true},
+		{"xyz", "", true},
+	}
+
+	for _, test := range tests {
+		format, err := parseFormat(test.name)
+		if test.err != (err != nil) || format != test.expected {
+			t.Errorf("%q: expected %q (error %t), got %q (%v)", test.name, test.expected, test.err, format, err)
+		}
+	}
+}
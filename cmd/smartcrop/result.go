@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/internal/cropjson"
+)
+
+// focalPointResult describes the focal point of an image.
+type focalPointResult struct {
+	X          int                 `json:"x"`
+	Y          int                 `json:"y"`
+	Normalized normalizedPoint     `json:"normalized"`
+	Safe       *rect               `json:"safe,omitempty"`
+	Source     cropjson.Dimensions `json:"source"`
+}
+
+// normalizedPoint is a point relative to the source dimensions, with both
+// values ranging from 0 to 1.
+type normalizedPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type rect struct {
+	X          int                     `json:"x"`
+	Y          int                     `json:"y"`
+	Width      int                     `json:"width"`
+	Height     int                     `json:"height"`
+	Normalized cropjson.NormalizedRect `json:"normalized"`
+}
+
+// printCrop analyzes the image stored at input and writes the chosen crop for
+// every requested size as JSON to w.
+func printCrop(w io.Writer, input string, opts cropOptions) error {
+	if len(opts.sizes) > 1 && opts.debugOutput != "" && !hasSizePlaceholder(opts.debugOutput) {
+		return errors.New("debug output filename needs a size placeholder like {size} when cropping to multiple sizes")
+	}
+
+	img, _, err := decodeFile(input)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	for _, s := range opts.sizes {
+		width, height := s.cropDimensions(img)
+		crops, err := opts.findCrops(img, width, height, 1+opts.debugCandidates)
+		if err != nil {
+			return err
+		}
+		if err := writeDebugImage(input, img, crops, width, height, opts); err != nil {
+			return err
+		}
+		if err := enc.Encode(cropjson.Describe(img.Bounds(), crops[0], width, height)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printFocalPoint analyzes the image stored at input and writes its focal point
+// as JSON to w.
+func printFocalPoint(w io.Writer, input string, opts cropOptions) error {
+	img, _, err := decodeFile(input)
+	if err != nil {
+		return err
+	}
+
+	analyzer, ok := opts.analyzer().(smartcrop.FocalPointAnalyzer)
+	if !ok {
+		return fmt.Errorf("the %s strategy can't find focal points", opts.strategy)
+	}
+	fp, err := analyzer.FindFocalPoint(img)
+	if err != nil {
+		return fmt.Errorf("can't find the focal point: %v", err)
+	}
+
+	bounds := img.Bounds()
+	res := focalPointResult{
+		X:          fp.Point.X - bounds.Min.X,
+		Y:          fp.Point.Y - bounds.Min.Y,
+		Normalized: normalizedPoint{X: fp.X, Y: fp.Y},
+		Source:     cropjson.Dimensions{Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+	if !fp.Safe.Empty() {
+		c := cropjson.New(bounds, fp.Safe)
+		res.Safe = &rect{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height, Normalized: c.Normalized}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
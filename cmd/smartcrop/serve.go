@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/muesli/smartcrop/httpcrop"
+	"github.com/muesli/smartcrop/iiif"
+	"github.com/muesli/smartcrop/urlcrop"
+)
+
+// serve runs an HTTP server cropping images on the fly, see packages httpcrop,
+// urlcrop and iiif.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "address to listen on")
+	root := fs.String("root", "", "directory to serve images from, only uploads are accepted if empty")
+	maxBytes := fs.Int64("max-bytes", httpcrop.DefaultMaxBytes, "maximum size of source images in bytes")
+	maxPixels := fs.Int("max-pixels", httpcrop.DefaultMaxPixels, "maximum pixel count of source images and responses")
+	maxAge := fs.Duration("max-age", httpcrop.DefaultMaxAge, "lifetime of responses in caches")
+	proxyURLs := fs.Bool("proxy-urls", false, "serve thumbor and imgproxy style URLs instead")
+	thumborKey := fs.String("thumbor-key", "", "security key of signed thumbor URLs (with -proxy-urls)")
+	imgproxyKey := fs.String("imgproxy-key", "", "hex-encoded key of signed imgproxy URLs (with -proxy-urls)")
+	imgproxySalt := fs.String("imgproxy-salt", "", "hex-encoded salt of signed imgproxy URLs (with -proxy-urls)")
+	iiifAPI := fs.Bool("iiif", false, "serve the IIIF Image API instead")
+	baseURL := fs.String("base-url", "", "public URL of the server, used in IIIF info.json (with -iiif)")
+	_ = fs.Parse(args)
+
+	if *proxyURLs && *iiifAPI {
+		return errors.New("-proxy-urls and -iiif can't be combined")
+	}
+
+	var handler http.Handler
+	if *iiifAPI {
+		if *root == "" {
+			return errors.New("-iiif requires -root")
+		}
+		h := iiif.NewHandler(*root)
+		h.BaseURL = *baseURL
+		h.MaxPixels = *maxPixels
+		h.MaxAge = *maxAge
+		handler = h
+	} else if *proxyURLs {
+		var origin urlcrop.Origin
+		if *root != "" {
+			origin = urlcrop.Dir(*root)
+		}
+		h := urlcrop.NewHandler(origin)
+		h.MaxBytes = *maxBytes
+		h.MaxPixels = *maxPixels
+		h.MaxAge = *maxAge
+		h.ThumborKey = []byte(*thumborKey)
+
+		var err error
+		if h.ImgproxyKey, err = hex.DecodeString(*imgproxyKey); err != nil {
+			return fmt.Errorf("invalid imgproxy key: %v", err)
+		}
+		if h.ImgproxySalt, err = hex.DecodeString(*imgproxySalt); err != nil {
+			return fmt.Errorf("invalid imgproxy salt: %v", err)
+		}
+		handler = h
+	} else {
+		h := httpcrop.NewHandler(*root)
+		h.MaxBytes = *maxBytes
+		h.MaxPixels = *maxPixels
+		h.MaxAge = *maxAge
+		handler = h
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+	}
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", *listen)
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// presets maps well-known target sizes to their dimensions.
+var presets = map[string]size{
+	"og-image":            {width: 1200, height: 630},
+	"twitter-card":        {width: 1200, height: 628},
+	"twitter-summary":     {width: 240, height: 240},
+	"facebook-cover":      {width: 820, height: 312},
+	"instagram-square":    {width: 1080, height: 1080},
+	"instagram-portrait":  {width: 1080, height: 1350},
+	"instagram-landscape": {width: 1080, height: 566},
+	"instagram-story":     {width: 1080, height: 1920},
+	"linkedin-post":       {width: 1200, height: 627},
+	"pinterest-pin":       {width: 1000, height: 1500},
+	"youtube-thumbnail":   {width: 1280, height: 720},
+}
+
+// size is a requested crop size. Zero values get derived from the image, see
+// getCropDimensions. If aspect is set, width and height only describe the
+// aspect ratio and the largest crop with that ratio gets chosen.
+type size struct {
+	width  int
+	height int
+	aspect bool
+}
+
+func (s size) String() string {
+	if s.aspect {
+		return strconv.Itoa(s.width) + ":" + strconv.Itoa(s.height)
+	}
+	return strconv.Itoa(s.width) + "x" + strconv.Itoa(s.height)
+}
+
+// cropDimensions returns the dimensions of the crop for img.
+func (s size) cropDimensions(img image.Image) (int, int) {
+	if !s.aspect {
+		return getCropDimensions(img, s.width, s.height)
+	}
+
+	b := img.Bounds()
+	if b.Dx()*s.height > b.Dy()*s.width {
+		return b.Dy() * s.width / s.height, b.Dy()
+	}
+	return b.Dx(), b.Dx() * s.height / s.width
+}
+
+func parseSize(s string) (size, error) {
+	w, h, err := parsePair(s, "x")
+	if err != nil {
+		return size{}, fmt.Errorf("invalid size %q, expected WxH", s)
+	}
+	return size{width: w, height: h}, nil
+}
+
+func parseAspect(s string) (size, error) {
+	w, h, err := parsePair(s, ":")
+	if err != nil || w == 0 || h == 0 {
+		return size{}, fmt.Errorf("invalid aspect ratio %q, expected W:H", s)
+	}
+	return size{width: w, height: h, aspect: true}, nil
+}
+
+func parsePreset(s string) (size, error) {
+	p, ok := presets[strings.TrimSpace(s)]
+	if !ok {
+		return size{}, fmt.Errorf("unknown preset %q, see -list-presets", s)
+	}
+	return p, nil
+}
+
+// parsePair parses two non-negative integers separated by sep.
+func parsePair(s, sep string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(s), sep)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected two values separated by %q", sep)
+	}
+	a, err := strconv.Atoi(parts[0])
+	if err != nil || a < 0 {
+		return 0, 0, fmt.Errorf("invalid value %q", parts[0])
+	}
+	b, err := strconv.Atoi(parts[1])
+	if err != nil || b < 0 {
+		return 0, 0, fmt.Errorf("invalid value %q", parts[1])
+	}
+	return a, b, nil
+}
+
+// sizeFlag is a flag.Value collecting sizes from repeated flags and
+// comma-separated lists into a list shared by several flags. parse
+// interprets a single value.
+type sizeFlag struct {
+	sizes *[]size
+	parse func(string) (size, error)
+}
+
+func (f sizeFlag) String() string {
+	if f.sizes == nil {
+		return ""
+	}
+	s := make([]string, 0, len(*f.sizes))
+	for _, v := range *f.sizes {
+		s = append(s, v.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (f sizeFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		s, err := f.parse(v)
+		if err != nil {
+			return err
+		}
+		*f.sizes = append(*f.sizes, s)
+	}
+	return nil
+}
+
+// listPresets writes the available presets to w.
+func listPresets(w io.Writer) {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%-20s %s\n", name, presets[name])
+	}
+}
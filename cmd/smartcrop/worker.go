@@ -0,0 +1,291 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/muesli/smartcrop/internal/cropjson"
+)
+
+// workerJob is a single line of input in worker mode. The ID is an arbitrary
+// JSON value which gets passed through to the result.
+type workerJob struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	Input   string          `json:"input"`
+	Sizes   []string        `json:"sizes"`
+	Output  string          `json:"output"`
+	Format  string          `json:"format"`
+	Quality int             `json:"quality"`
+	Resize  *bool           `json:"resize"`
+	Hints   hints           `json:"hints"`
+}
+
+// workerResult is written as a single line for every job. Seq is the
+// zero-based line number of the job in the input, Error is only set if the
+// job failed.
+type workerResult struct {
+	Seq   int             `json:"seq"`
+	ID    json.RawMessage `json:"id,omitempty"`
+	Input string          `json:"input,omitempty"`
+	Crops []workerCrop    `json:"crops,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// workerCrop is the crop for one of the requested sizes and the file it was
+// written to, if any.
+type workerCrop struct {
+	cropjson.Crop
+	Output string `json:"output,omitempty"`
+}
+
+type workerLine struct {
+	seq  int
+	data []byte
+}
+
+// worker reads jobs as JSON lines from stdin and writes one JSON line per job
+// to stdout, until stdin is closed. Failed jobs are reported in their result
+// and don't stop the worker.
+func worker(args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	workers := fs.Int("workers", runtime.NumCPU(), "number of parallel workers")
+	ordered := fs.Bool("ordered", false, "write results in the order of the jobs, otherwise as soon as they are done")
+	cacheDir := fs.String("cache-dir", "", "directory to cache analysis results in, reused for identical images")
+	_ = fs.Parse(args)
+
+	if *workers < 1 {
+		*workers = 1
+	}
+	return runWorker(os.Stdin, os.Stdout, *workers, *ordered, *cacheDir)
+}
+
+func runWorker(r io.Reader, w io.Writer, workers int, ordered bool, cacheDir string) error {
+	lines := make(chan workerLine)
+	results := make(chan workerResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range lines {
+				results <- runJob(l, cacheDir)
+			}
+		}()
+	}
+
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- writeResults(w, results, ordered)
+	}()
+
+	br := bufio.NewReader(r)
+	var readErr error
+	for seq := 0; ; {
+		line, err := br.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			lines <- workerLine{seq: seq, data: line}
+			seq++
+		}
+		if err != nil {
+			if err != io.EOF {
+				readErr = fmt.Errorf("can't read jobs: %v", err)
+			}
+			break
+		}
+	}
+	close(lines)
+	wg.Wait()
+	close(results)
+
+	if err := <-writeErr; err != nil {
+		return err
+	}
+	return readErr
+}
+
+// writeResults writes every result as a JSON line to w. In ordered mode
+// results which finished early are held back until all their predecessors
+// have been written.
+func writeResults(w io.Writer, results <-chan workerResult, ordered bool) error {
+	enc := json.NewEncoder(w)
+	pending := make(map[int]workerResult)
+	next := 0
+
+	var err error
+	for res := range results {
+		// keep draining after a write error so the workers don't block
+		if err != nil {
+			continue
+		}
+		if !ordered {
+			err = enc.Encode(res)
+			continue
+		}
+
+		pending[res.Seq] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err = enc.Encode(res); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("can't write result: %v", err)
+	}
+	return nil
+}
+
+// runJob processes a single line of input. Errors, including panics caused by
+// unexpected input, are reported as part of the result.
+func runJob(l workerLine, cacheDir string) (res workerResult) {
+	res.Seq = l.seq
+	defer func() {
+		if r := recover(); r != nil {
+			res.Crops = nil
+			res.Error = fmt.Sprintf("internal error: %v", r)
+		}
+	}()
+
+	var j workerJob
+	if err := json.Unmarshal(l.data, &j); err != nil {
+		res.Error = fmt.Sprintf("invalid job: %v", err)
+		return res
+	}
+	res.ID = j.ID
+	res.Input = j.Input
+
+	crops, err := j.process(cacheDir)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Crops = crops
+	return res
+}
+
+// process crops the input of the job to every requested size. Without an
+// output template only the crops get determined.
+func (j workerJob) process(cacheDir string) ([]workerCrop, error) {
+	if j.Input == "" || j.Input == "-" {
+		return nil, errors.New("no input file given")
+	}
+
+	opts := cropOptions{
+		resize:  true,
+		quality: 85,
+		boosts:  j.Hints.boosts(),
+
+		cacheDir: cacheDir,
+	}
+	if j.Resize != nil {
+		opts.resize = *j.Resize
+	}
+	if j.Quality > 0 {
+		opts.quality = j.Quality
+	}
+	var err error
+	if opts.format, err = parseFormat(j.Format); err != nil {
+		return nil, err
+	}
+	for _, s := range j.Sizes {
+		sz, err := parseSizeSpec(s)
+		if err != nil {
+			return nil, err
+		}
+		opts.sizes = append(opts.sizes, sz)
+	}
+	if len(opts.sizes) == 0 {
+		opts.sizes = []size{{}}
+	}
+	if j.Output == "-" {
+		return nil, errors.New("can't write to stdout in worker mode")
+	}
+	if len(opts.sizes) > 1 && j.Output != "" && !hasSizePlaceholder(j.Output) {
+		return nil, errors.New("output filename needs a size placeholder like {size} when cropping to multiple sizes")
+	}
+
+	img, inFormat, err := decodeFile(j.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	var crops []workerCrop
+	for _, s := range opts.sizes {
+		width, height := s.cropDimensions(img)
+		cs, err := opts.findCrops(img, width, height, 1)
+		if err != nil {
+			return nil, err
+		}
+		c := cs[0]
+		wc := workerCrop{Crop: cropjson.Describe(img.Bounds(), c, width, height)}
+
+		if j.Output != "" {
+			wc.Output = expandName(j.Output, j.Input, width, height)
+			format, err := outputFormat(opts.format, wc.Output, inFormat)
+			if err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(filepath.Dir(wc.Output), 0755); err != nil {
+				return nil, fmt.Errorf("can't create output directory: %v", err)
+			}
+			if err := encodeFile(wc.Output, cropImage(img, c.Rectangle, width, height, opts.resize), format, opts.quality); err != nil {
+				return nil, err
+			}
+		}
+		crops = append(crops, wc)
+	}
+	return crops, nil
+}
+
+// parseSizeSpec parses a size as accepted by -size, -aspect or -preset.
+func parseSizeSpec(s string) (size, error) {
+	switch {
+	case strings.Contains(s, ":"):
+		return parseAspect(s)
+	case strings.Contains(s, "x"):
+		return parseSize(s)
+	default:
+		return parsePreset(s)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readResults decodes the JSON lines written by runWorker.
+func readResults(t *testing.T, b *bytes.Buffer) []workerResult {
+	var results []workerResult
+	s := bufio.NewScanner(b)
+	for s.Scan() {
+		var res workerResult
+		if err := json.Unmarshal(s.Bytes(), &res); err != nil {
+			t.Fatalf("invalid result line %q: %v", s.Text(), err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestWorker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smartcrop-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // temporary directory
+
+	output := filepath.Join(dir, "{name}_{size}.png")
+	jobs := strings.Join([]string{
+		`{"id": "a", "input": "../../examples/gopher.jpg", "sizes": ["100x100", "16:9"], "output": ` + fmt.Sprintf("%q", output) + `}`,
+		``,
+		`not json`,
+		`{"id": 3, "input": "../../examples/missing.jpg", "sizes": ["100x100"]}`,
+		`{"input": "../../examples/gopher.jpg", "sizes": ["no-such-preset"]}`,
+		`{"input": "../../examples/gopher.jpg", "sizes": ["100x100", "50x50"], "output": "thumb.jpg"}`,
+		`{"input": "../../examples/gopher.jpg"}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := runWorker(strings.NewReader(jobs), &out, 4, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	results := readResults(t, &out)
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results, got %d", len(results))
+	}
+	for i, res := range results {
+		if res.Seq != i {
+			t.Fatalf("expected ordered results, got seq %d at position %d", res.Seq, i)
+		}
+	}
+
+	res := results[0]
+	if res.Error != "" || string(res.ID) != `"a"` || len(res.Crops) != 2 {
+		t.Fatalf("expected 2 crops for job a, got %+v", res)
+	}
+	if c := res.Crops[0]; c.Target.Width != 100 || c.Target.Height != 100 || c.Output != filepath.Join(dir, "gopher_100x100.png") {
+		t.Errorf("expected a 100x100 crop written to gopher_100x100.png, got %+v", c)
+	}
+	if c := res.Crops[1]; c.Target.Width != 504 || c.Target.Height != 284 {
+		t.Errorf("expected a 504x284 crop, got %+v", c)
+	}
+	for _, c := range res.Crops {
+		if _, err := os.Stat(c.Output); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for i, expected := range map[int]string{
+		1: "invalid job",
+		2: "can't open input file",
+		3: "unknown preset",
+		4: "size placeholder",
+	} {
+		if !strings.Contains(results[i].Error, expected) || results[i].Crops != nil {
+			t.Errorf("job %d: expected an error containing %q, got %+v", i, expected, results[i])
+		}
+	}
+	if string(results[2].ID) != "3" {
+		t.Errorf("expected the id of a failed job to be passed through, got %s", results[2].ID)
+	}
+
+	// without sizes the whole image is described
+	if res := results[5]; res.Error != "" || len(res.Crops) != 1 || res.Crops[0].Output != "" {
+		t.Errorf("expected a single crop without output, got %+v", res)
+	}
+}
+
+func TestWorkerUnordered(t *testing.T) {
+	var jobs []string
+	for i := 0; i < 8; i++ {
+		jobs = append(jobs, fmt.Sprintf(`{"id": %d, "input": "../../examples/gopher.jpg", "sizes": ["50x50"]}`, i))
+	}
+
+	var out bytes.Buffer
+	if err := runWorker(strings.NewReader(strings.Join(jobs, "\n")+"\n"), &out, 3, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	results := readResults(t, &out)
+	if len(results) != len(jobs) {
+		t.Fatalf("expected %d results, got %d", len(jobs), len(results))
+	}
+	seen := make(map[int]bool)
+	for _, res := range results {
+		if res.Error != "" || string(res.ID) != fmt.Sprint(res.Seq) {
+			t.Errorf("expected job %d to succeed, got %+v", res.Seq, res)
+		}
+		seen[res.Seq] = true
+	}
+	if len(seen) != len(jobs) {
+		t.Fatalf("expected every job exactly once, got %v", seen)
+	}
+}
@@ -25,10 +25,6 @@
  *		Bjørn Erik Pedersen <bjorn.erik.pedersen@gmail.com>
  */
 
-/*
-Package smartcrop implements a content aware image cropping library based on
-Jonas Wagner's smartcrop.js https://github.com/jwagner/smartcrop.js
-*/
 package smartcrop
 
 import (
@@ -37,8 +33,11 @@ import (
 	"image/color"
 	"image/jpeg"
 	"image/png"
+	"math"
 	"os"
 	"path/filepath"
+
+	"golang.org/x/image/draw"
 )
 
 func debugOutput(debug bool, img *image.RGBA, debugType string) {
@@ -106,3 +105,38 @@ func drawDebugCrop(topCrop Crop, o *image.RGBA) {
 		}
 	}
 }
+
+// DebugImage returns a copy of img at its original resolution, tinted by the
+// importance map of crop like the debug output of the analyzer, with the
+// outlines of the given runner-up candidates and of crop drawn on top. The
+// crops are in the coordinate space of img, as returned by FindBestCrops; the
+// returned image starts at (0, 0).
+func DebugImage(img image.Image, crop Crop, candidates []Crop) *image.RGBA {
+	b := img.Bounds()
+	o := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Copy(o, image.Pt(0, 0), img, b, draw.Src, nil)
+
+	crop.Rectangle = crop.Sub(b.Min)
+	drawDebugCrop(crop, o)
+
+	// scale the outlines with the image, so they stay visible on large images
+	thickness := int(math.Max(1, math.Min(float64(b.Dx()), float64(b.Dy()))/300))
+	for _, c := range candidates {
+		drawOutline(o, c.Sub(b.Min), color.RGBA{255, 255, 0, 255}, thickness)
+	}
+	drawOutline(o, crop.Rectangle, color.RGBA{0, 255, 255, 255}, thickness*2)
+
+	return o
+}
+
+func drawOutline(o *image.RGBA, r image.Rectangle, c color.RGBA, thickness int) {
+	edges := []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thickness),
+		image.Rect(r.Min.X, r.Max.Y-thickness, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+thickness, r.Max.Y),
+		image.Rect(r.Max.X-thickness, r.Min.Y, r.Max.X, r.Max.Y),
+	}
+	for _, e := range edges {
+		draw.Draw(o, e.Intersect(o.Bounds()), &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+}
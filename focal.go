@@ -0,0 +1,217 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// size of the cells the importance gets summed up in, in pixels of the
+	// prescaled image
+	focalCell = 8
+	// standard deviation of the blur applied before finding the focal
+	// point, in cells
+	focalBlur = 1.5
+	// share of the importance the safe rectangle contains
+	focalSafeShare = 0.8
+)
+
+// FocalPointAnalyzer is implemented by analyzers which can find the focal
+// point of an image, e.g. for CSS object-position or the focal point
+// parameters of CDNs
+type FocalPointAnalyzer interface {
+	Analyzer
+	FindFocalPoint(img image.Image) (FocalPoint, error)
+}
+
+// FocalPoint is the centre of the important content of an image. Unlike a
+// crop, it doesn't depend on the target dimensions, so it suits any viewport.
+type FocalPoint struct {
+	// Point is the focal point in pixels of the image.
+	Point image.Point
+	// X and Y are the focal point relative to the bounds of the image,
+	// ranging from 0 to 1.
+	X, Y float64
+	// Safe is a rectangle around the focal point containing most of the
+	// important content. It's empty if nothing of importance was found.
+	Safe image.Rectangle
+}
+
+// FindFocalPoint returns the focal point of img, derived from the same
+// features, boosts and detectors the crops get scored by.
+func (o smartcropAnalyzer) FindFocalPoint(img image.Image) (FocalPoint, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return FocalPoint{}, ErrInvalidDimensions
+	}
+	features, extraMap, prescalefactor := o.features([]image.Image{img})
+	textBoosts, _ := o.textBoosts(img, prescalefactor)
+	boostMap := makeBoostMap(features, append(textBoosts, o.boosts...), b.Min, prescalefactor)
+
+	width, height := features.Bounds().Dx(), features.Bounds().Dy()
+	plane := interest(features, boostMap, extraMap)
+	gw := int(math.Max(1, float64(width/focalCell)))
+	gh := int(math.Max(1, float64(height/focalCell)))
+	grid := shrink(plane, width, height, gw, gh)
+
+	// the peak of the blurred grid is the centre of the densest cluster of
+	// importance, rather than a single outlier
+	blurred := gaussianBlur(grid, gw, gh, focalBlur)
+	peak := 0
+	for i, v := range blurred {
+		if v > blurred[peak] {
+			peak = i
+		}
+	}
+	if blurred[peak] <= 0 {
+		c := image.Pt(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2)
+		return FocalPoint{Point: c, X: 0.5, Y: 0.5}, nil
+	}
+
+	// cell coordinates to pixels of the image
+	toImage := func(x, y int) image.Point {
+		return image.Pt(
+			clamp(int(math.Round(float64(x*width/gw)/prescalefactor)), 0, b.Dx()),
+			clamp(int(math.Round(float64(y*height/gh)/prescalefactor)), 0, b.Dy()),
+		).Add(b.Min)
+	}
+
+	// refine the focal point to the centroid of the importance around the
+	// peak cell
+	cells := image.Rect(peak%gw-1, peak/gw-1, peak%gw+2, peak/gw+2).Intersect(image.Rect(0, 0, gw, gh))
+	area := image.Rectangle{Min: toImage(cells.Min.X, cells.Min.Y), Max: toImage(cells.Max.X, cells.Max.Y)}
+	px, py := centroid(plane, width, image.Rect(cells.Min.X*width/gw, cells.Min.Y*height/gh, cells.Max.X*width/gw, cells.Max.Y*height/gh))
+	p := image.Pt(
+		clamp(int(math.Round(px/prescalefactor)), 0, b.Dx()-1),
+		clamp(int(math.Round(py/prescalefactor)), 0, b.Dy()-1),
+	).Add(b.Min)
+	if !p.In(area) {
+		p = image.Pt((area.Min.X+area.Max.X)/2, (area.Min.Y+area.Max.Y)/2)
+	}
+
+	safe := safeCells(grid, gw, gh, peak)
+	return FocalPoint{
+		Point: p,
+		X:     float64(p.X-b.Min.X) / float64(b.Dx()),
+		Y:     float64(p.Y-b.Min.Y) / float64(b.Dy()),
+		Safe:  image.Rectangle{Min: toImage(safe.Min.X, safe.Min.Y), Max: toImage(safe.Max.X, safe.Max.Y)},
+	}, nil
+}
+
+// interest returns the importance of every pixel of the feature map o,
+// weighted like the score of a crop. Regions to avoid don't count.
+func interest(o *image.RGBA, boostMap, extraMap []float64) []float64 {
+	width := o.Bounds().Dx()
+	height := o.Bounds().Dy()
+	plane := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := o.RGBAAt(x, y)
+			det := float64(c.G) / 255.0
+			v := det*detailWeight +
+				float64(c.R)/255.0*(det+skinBias)*skinWeight +
+				float64(c.B)/255.0*(det+saturationBias)*saturationWeight
+			if boostMap != nil {
+				v += math.Max(0, boostMap[y*width+x]) * boostWeight
+			}
+			if extraMap != nil {
+				v += math.Max(0, extraMap[y*width+x])
+			}
+			plane[y*width+x] = v
+		}
+	}
+	return plane
+}
+
+// centroid returns the centre of mass of the plane within r, or the centre of
+// r if it's empty.
+func centroid(plane []float64, width int, r image.Rectangle) (float64, float64) {
+	var sum, sx, sy float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			v := plane[y*width+x]
+			sum += v
+			sx += v * (float64(x) + 0.5)
+			sy += v * (float64(y) + 0.5)
+		}
+	}
+	if sum <= 0 {
+		return float64(r.Min.X+r.Max.X) / 2, float64(r.Min.Y+r.Max.Y) / 2
+	}
+	return sx / sum, sy / sum
+}
+
+// safeCells returns the rectangle of cells containing focalSafeShare of the
+// importance of the grid. It grows from the start cell, always by the row or
+// column adding the most importance.
+func safeCells(grid []float64, gw, gh, start int) image.Rectangle {
+	var total float64
+	for _, v := range grid {
+		total += math.Max(0, v)
+	}
+	sum := func(r image.Rectangle) float64 {
+		var s float64
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				s += math.Max(0, grid[y*gw+x])
+			}
+		}
+		return s
+	}
+
+	r := image.Rect(start%gw, start/gw, start%gw+1, start/gw+1)
+	covered := sum(r)
+	for covered < focalSafeShare*total {
+		var strips []image.Rectangle
+		if r.Min.X > 0 {
+			strips = append(strips, image.Rect(r.Min.X-1, r.Min.Y, r.Min.X, r.Max.Y))
+		}
+		if r.Max.X < gw {
+			strips = append(strips, image.Rect(r.Max.X, r.Min.Y, r.Max.X+1, r.Max.Y))
+		}
+		if r.Min.Y > 0 {
+			strips = append(strips, image.Rect(r.Min.X, r.Min.Y-1, r.Max.X, r.Min.Y))
+		}
+		if r.Max.Y < gh {
+			strips = append(strips, image.Rect(r.Min.X, r.Max.Y, r.Max.X, r.Max.Y+1))
+		}
+		if len(strips) == 0 {
+			break
+		}
+
+		best, bestSum := strips[0], -1.0
+		for _, s := range strips {
+			if v := sum(s); v > bestSum {
+				best, bestSum = s, v
+			}
+		}
+		r = r.Union(best)
+		covered += bestSum
+	}
+	return r
+}
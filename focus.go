@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// size of the blocks the sharpness gets measured in, as fraction of the
+	// smaller image dimension
+	focusBlock = 1.0 / 16
+	// sharpness relative to the sharpest block below which a region counts
+	// as out of focus
+	focusThreshold = 0.25
+)
+
+// FocusDetector is a Detector finding the regions of an image which are in
+// focus. The sharpness of a block is the standard deviation of its Laplacian,
+// which is low for defocused regions like the blurred background of a
+// portrait, even if they contain large, high-contrast shapes.
+type FocusDetector struct{}
+
+// Detect returns the focus of every pixel of img: regions in focus range from 0
+// to 1, defocused regions are negative.
+func (d FocusDetector) Detect(img *image.RGBA) []float64 {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	return d.detectLaplacian(laplacian(makeCies(img), width, height), width, height)
+}
+
+// detectLaplacian returns the focus of every pixel of an image with the
+// Laplacian l of its lightness.
+func (FocusDetector) detectLaplacian(l []float64, width, height int) []float64 {
+	if width == 0 || height == 0 {
+		return make([]float64, width*height)
+	}
+
+	sq := make([]float64, len(l))
+	for i, v := range l {
+		sq[i] = v * v
+	}
+	radius := int(math.Max(1, math.Min(float64(width), float64(height))*focusBlock/2))
+	mean := boxMean(l, width, height, radius)
+	meanSq := boxMean(sq, width, height, radius)
+
+	focus := make([]float64, len(l))
+	for i := range focus {
+		// the standard deviation is less dominated by the sharpest edges
+		// than the variance
+		focus[i] = math.Sqrt(math.Max(0, meanSq[i]-mean[i]*mean[i]))
+	}
+	normalize(focus)
+	for i, v := range focus {
+		if v < focusThreshold {
+			focus[i] = v/focusThreshold - 1
+		} else {
+			focus[i] = (v - focusThreshold) / (1 - focusThreshold)
+		}
+	}
+	return focus
+}
+
+// laplacian returns the Laplacian of the lightness cies of an image, zero
+// along its edges.
+func laplacian(cies []float64, width, height int) []float64 {
+	l := make([]float64, len(cies))
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			l[y*width+x] = cies[y*width+x]*4.0 -
+				cies[x+(y-1)*width] -
+				cies[x-1+y*width] -
+				cies[x+1+y*width] -
+				cies[x+(y+1)*width]
+		}
+	}
+	return l
+}
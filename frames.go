@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+const (
+	// frames darker, blurrier or with less detail than these get penalized
+	frameMinBrightness = 0.25
+	frameMinSharpness  = 100.0
+	frameMinDetail     = 0.02
+	// typical magnitude of the totals of crops, averaged over the sampled
+	// pixels
+	frameScoreScale = 0.01
+)
+
+// FrameAnalyzer is implemented by analyzers which can pick the best frame out
+// of a set of candidates, e.g. for thumbnails of a video
+type FrameAnalyzer interface {
+	Analyzer
+	FindBestFrames(frames []image.Image, width, height int) ([]FrameCrop, error)
+}
+
+// FrameCrop is the best crop of a frame and its rating among other frames.
+type FrameCrop struct {
+	Crop
+	// Frame is the index of the frame.
+	Frame int
+	// Quality ranges from 0 to 1 and is lower for dark, blurry and
+	// low-detail frames.
+	Quality float64
+	// Value is the score of the crop mapped to the range of 0 to 1,
+	// weighted by the quality of the frame. Frames get ranked by it.
+	Value float64
+}
+
+// FindBestFrames returns the best crop of every frame, ranked by their value
+// with the best frame first. The frames may differ in size.
+func (o smartcropAnalyzer) FindBestFrames(frames []image.Image, width, height int) ([]FrameCrop, error) {
+	if len(frames) == 0 {
+		return nil, ErrNoFrames
+	}
+	if width == 0 && height == 0 {
+		return nil, ErrInvalidDimensions
+	}
+
+	var res []FrameCrop
+	for i, img := range frames {
+		res = append(res, o.rateFrame(img, width, height))
+		res[i].Frame = i
+	}
+
+	// a stable sort keeps the first of equally rated frames on top
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Value > res[j].Value
+	})
+	return res, nil
+}
+
+// rateFrame finds the best crop of img and rates it.
+func (o smartcropAnalyzer) rateFrame(img image.Image, width, height int) FrameCrop {
+	scale := math.Min(float64(img.Bounds().Dx())/float64(width), float64(img.Bounds().Dy())/float64(height))
+	prescalefactor := o.prescaleFactor(img)
+	lowimg := o.downscale(img, prescalefactor)
+	lowWidth, lowHeight := lowimg.Bounds().Dx(), lowimg.Bounds().Dy()
+	cies := makeCies(lowimg)
+	l := laplacian(cies, lowWidth, lowHeight)
+	features := detectFeatures(o.logger, lowimg, l)
+	extraMap := o.detect(lowimg, l)
+	boostMap := makeBoostMap(features, o.boosts, img.Bounds().Min, prescalefactor)
+
+	cropWidth, cropHeight := chop(float64(width)*scale*prescalefactor), chop(float64(height)*scale*prescalefactor)
+	realMinScale := math.Min(maxScale, math.Max(1.0/scale, minScale))
+	crop := analyse(o.logger, features, boostMap, extraMap, cropWidth, cropHeight, realMinScale, 1)[0]
+
+	quality := qualityFactor(brightness(cies), frameMinBrightness) *
+		qualityFactor(sharpness(l, lowWidth, lowHeight), frameMinSharpness) *
+		qualityFactor(detail(features, crop.Rectangle), frameMinDetail)
+
+	// totals are averages over all pixels of a crop, of which only every
+	// scoreDownSample-th in each direction is sampled; a logistic function
+	// maps the average over the sampled pixels to the range of 0 to 1
+	value := 1 / (1 + math.Exp(-crop.Score.Total*scoreDownSample*scoreDownSample/frameScoreScale))
+
+	crops := []Crop{crop}
+	upscale(crops, prescalefactor, img.Bounds().Min)
+	return FrameCrop{
+		Crop:    crops[0],
+		Quality: quality,
+		Value:   value * quality,
+	}
+}
+
+// qualityFactor returns 1 for values of at least threshold and scales down
+// linearly below it.
+func qualityFactor(v, threshold float64) float64 {
+	return math.Max(0, math.Min(1, v/threshold))
+}
+
+// brightness returns the mean lightness of an image, from 0 to 1.
+func brightness(cies []float64) float64 {
+	if len(cies) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, c := range cies {
+		sum += c
+	}
+	return sum / float64(len(cies)) / 255
+}
+
+// sharpness returns the variance of the Laplacian l of an image, which is low
+// for blurry images.
+func sharpness(l []float64, width, height int) float64 {
+	var sum, sumSq, n float64
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			sum += l[y*width+x]
+			sumSq += l[y*width+x] * l[y*width+x]
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	mean := sum / n
+	return sumSq/n - mean*mean
+}
+
+// detail returns the mean detail within r of the feature map o, from 0 to 1.
+func detail(o *image.RGBA, r image.Rectangle) float64 {
+	r = r.Intersect(o.Bounds())
+	if r.Empty() {
+		return 0
+	}
+	var sum float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum += float64(o.RGBAAt(x, y).G)
+		}
+	}
+	return sum / float64(r.Dx()*r.Dy()) / 255
+}
@@ -0,0 +1,354 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+/*
+Package httpcrop provides an http.Handler which crops images on the fly.
+
+Images are either uploaded in the body of a POST or PUT request, as raw body
+or as the "image" field of a multipart form, or loaded from a local directory
+by passing their path in the request URL or the "path" parameter of a GET
+request. The following query parameters are supported:
+
+	width, height  dimensions of the crop, a square crop if both are missing
+	resize         scale the crop to width x height, defaults to true
+	format         jpeg, png, gif, bmp, tiff or json, defaults to the source format
+	quality        jpeg quality, defaults to 85
+
+With format=json the coordinates of the chosen crop get returned instead of an
+image, e.g. to let a CDN do the actual cropping.
+*/
+package httpcrop
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/internal/codec"
+	"github.com/muesli/smartcrop/internal/cropjson"
+	"github.com/muesli/smartcrop/internal/serve"
+	"github.com/muesli/smartcrop/nfnt"
+	"github.com/muesli/smartcrop/options"
+)
+
+const (
+	// DefaultMaxBytes is the default size limit of source images.
+	DefaultMaxBytes = serve.DefaultMaxBytes
+	// DefaultMaxPixels is the default limit of the pixel count of source
+	// images and of responses.
+	DefaultMaxPixels = serve.DefaultMaxPixels
+	// DefaultMaxAge is the default lifetime of responses in caches.
+	DefaultMaxAge = serve.DefaultMaxAge
+
+	defaultQuality = 85
+)
+
+// Handler is an http.Handler cropping images. The zero value only accepts
+// uploaded images and uses the default analyzer and limits.
+type Handler struct {
+	// Root is the directory images referenced by path get loaded from. If
+	// empty, only uploaded images are accepted.
+	Root string
+	// Analyzer finds the crops.
+	Analyzer smartcrop.Analyzer
+	// Resizer scales the crops to the requested size.
+	Resizer options.Resizer
+	// MaxBytes limits the size of source images.
+	MaxBytes int64
+	// MaxPixels limits the pixel count of source images and of responses,
+	// so small but huge images can't exhaust the memory of the server.
+	MaxPixels int
+	// MaxAge is the lifetime of responses announced in the Cache-Control
+	// header.
+	MaxAge time.Duration
+}
+
+// NewHandler returns a Handler serving images from the directory root, with
+// the default analyzer and limits.
+func NewHandler(root string) *Handler {
+	resizer := nfnt.NewDefaultResizer()
+	return &Handler{
+		Root:      root,
+		Analyzer:  smartcrop.NewAnalyzer(resizer),
+		Resizer:   resizer,
+		MaxBytes:  DefaultMaxBytes,
+		MaxPixels: DefaultMaxPixels,
+		MaxAge:    DefaultMaxAge,
+	}
+}
+
+// params are the parsed query parameters of a request.
+type params struct {
+	width   int
+	height  int
+	resize  bool
+	format  string
+	quality int
+}
+
+// key identifies the output for a source image, used for the ETag.
+func (p params) key() string {
+	return fmt.Sprintf("%dx%d,%t,%s,%d", p.width, p.height, p.resize, p.format, p.quality)
+}
+
+func parseParams(r *http.Request) (params, error) {
+	q := r.URL.Query()
+	p := params{
+		resize:  true,
+		quality: defaultQuality,
+	}
+
+	var err error
+	if p.width, err = intParam(q.Get("width"), 0); err != nil {
+		return p, fmt.Errorf("invalid width: %v", err)
+	}
+	if p.height, err = intParam(q.Get("height"), 0); err != nil {
+		return p, fmt.Errorf("invalid height: %v", err)
+	}
+	if p.quality, err = intParam(q.Get("quality"), defaultQuality); err != nil || p.quality < 1 || p.quality > 100 {
+		return p, errors.New("invalid quality, expected 1 to 100")
+	}
+	if v := q.Get("resize"); v != "" {
+		if p.resize, err = strconv.ParseBool(v); err != nil {
+			return p, errors.New("invalid resize, expected true or false")
+		}
+	}
+
+	switch f := strings.ToLower(q.Get("format")); f {
+	case "", "json":
+		p.format = f
+	default:
+		p.format = codec.FormatFromExtension("." + f)
+		if !codec.CanEncode(p.format) {
+			return p, fmt.Errorf("unsupported format %q", f)
+		}
+	}
+
+	return p, nil
+}
+
+func intParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return i, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, err := parseParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var src []byte
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		src, err = h.load(r)
+	case http.MethodPost, http.MethodPut:
+		src, err = h.upload(r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		serve.Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// the ETag covers the source image as well as the requested output
+	hash := sha256.New()
+	_, _ = hash.Write(src)
+	_, _ = io.WriteString(hash, p.key())
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.limits().Age().Seconds())))
+	if match := r.Header.Get("If-None-Match"); match != "" && (match == "*" || strings.Contains(match, etag)) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	img, format, err := h.limits().Decode(bytes.NewReader(src))
+	if err != nil {
+		serve.Error(w, err, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	width, height := cropDimensions(img, p.width, p.height)
+	crop, err := h.findCrop(img, width, height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if p.format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead {
+			_ = json.NewEncoder(w).Encode(cropjson.Describe(img.Bounds(), crop, width, height))
+		}
+		return
+	}
+
+	if p.format == "" {
+		p.format = format
+		if !codec.CanEncode(p.format) {
+			p.format = "jpeg"
+		}
+	}
+
+	out := serve.SubImage(img, crop.Rectangle)
+	if p.resize && (out.Bounds().Dx() != width || out.Bounds().Dy() != height) {
+		if err := h.limits().CheckSize(width, height); err != nil {
+			serve.Error(w, err, http.StatusBadRequest)
+			return
+		}
+		out = serve.Resizer(h.Resizer).Resize(out, uint(width), uint(height))
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, out, p.format, p.quality); err != nil {
+		http.Error(w, "can't encode image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", codec.ContentType(p.format))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if r.Method != http.MethodHead {
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// load reads the image referenced by the path parameter or the request path
+// from the root directory.
+func (h *Handler) load(r *http.Request) ([]byte, error) {
+	if h.Root == "" {
+		return nil, serve.ErrNotFound
+	}
+	p := r.URL.Query().Get("path")
+	if p == "" {
+		p = r.URL.Path
+	}
+	// cleaning the path as an absolute one strips any attempt to leave root
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return nil, serve.ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(h.Root, filepath.FromSlash(p)))
+	if err != nil {
+		return nil, serve.ErrNotFound
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil, serve.ErrNotFound
+	}
+	return h.limits().Read(f)
+}
+
+// upload reads the image sent in the request body, either raw or as "image"
+// field of a multipart form.
+func (h *Handler) upload(r *http.Request) ([]byte, error) {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct != "multipart/form-data" {
+		return h.limits().Read(r.Body)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("missing image field")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "image" {
+			return h.limits().Read(part)
+		}
+	}
+}
+
+func (h *Handler) findCrop(img image.Image, width, height int) (smartcrop.Crop, error) {
+	analyzer := h.Analyzer
+	if analyzer == nil {
+		analyzer = smartcrop.NewAnalyzer(serve.Resizer(h.Resizer))
+	}
+
+	if ca, ok := analyzer.(smartcrop.CropsAnalyzer); ok {
+		crops, err := ca.FindBestCrops(img, width, height, 1)
+		if err != nil {
+			return smartcrop.Crop{}, err
+		}
+		return crops[0], nil
+	}
+
+	r, err := analyzer.FindBestCrop(img, width, height)
+	return smartcrop.Crop{Rectangle: r}, err
+}
+
+func (h *Handler) limits() serve.Limits {
+	return serve.Limits{MaxBytes: h.MaxBytes, MaxPixels: h.MaxPixels, MaxAge: h.MaxAge}
+}
+
+// cropDimensions falls back to a square of the smaller image dimension if
+// neither width nor height are set.
+func cropDimensions(img image.Image, width, height int) (int, int) {
+	if width == 0 && height == 0 {
+		b := img.Bounds()
+		width, height = b.Dx(), b.Dy()
+		if width > height {
+			width = height
+		} else {
+			height = width
+		}
+	}
+	return width, height
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package httpcrop
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/internal/cropjson"
+)
+
+func TestHandlerPath(t *testing.T) {
+	h := NewHandler("../examples")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/gopher.jpg?width=250&height=250&format=json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var res cropjson.Crop
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	expected := image.Rect(464, 24, 719, 279)
+	if r := image.Rect(res.X, res.Y, res.X+res.Width, res.Y+res.Height); r != expected {
+		t.Fatalf("expected %v, got %v", expected, r)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	req := httptest.NewRequest("GET", "/gopher.jpg?width=250&height=250&format=json", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected status 304, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?path=../../smartcrop.go", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 when leaving the root, got %d", rec.Code)
+	}
+
+	h.Analyzer, _ = smartcrop.NewStrategyAnalyzer("centre", h.Resizer)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/gopher.jpg?width=250&height=250&format=json", nil))
+	res = cropjson.Crop{}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Score != nil {
+		t.Fatalf("expected no score from an analyzer without scores, got %+v", res.Score)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/gopher.jpg?width=30000&height=30000", nil))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413 for a huge output, got %d", rec.Code)
+	}
+}
+
+func TestHandlerUpload(t *testing.T) {
+	src, err := ioutil.ReadFile("../examples/gopher.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler("")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/?width=100&height=50&format=png", bytes.NewReader(src)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("expected image/png, got %s", ct)
+	}
+	img, format, err := image.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || img.Bounds().Dx() != 100 || img.Bounds().Dy() != 50 {
+		t.Fatalf("expected a 100x50 png, got a %dx%d %s", img.Bounds().Dx(), img.Bounds().Dy(), format)
+	}
+
+	h.MaxBytes = 1024
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", bytes.NewReader(src)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", rec.Code)
+	}
+}
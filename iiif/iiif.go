@@ -0,0 +1,422 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+/*
+Package iiif implements the IIIF Image API 3.0 (https://iiif.io/api/image/3.0/)
+at compliance level 1 on top of a local directory of images, using smartcrop
+to find content aware regions.
+
+Requests look like
+
+	{identifier}/{region}/{size}/{rotation}/{quality}.{format}
+	{identifier}/info.json
+
+where identifier is the (URL encoded) path of an image within the directory.
+Besides level 1 the handler supports the regionByPct, sizeByPct,
+sizeByConfinedWh, sizeUpscaling, rotationBy90s and mirroring features, the
+gray quality and the png, gif and tif formats.
+
+The "square" region is chosen by the smartcrop analyzer rather than centered.
+In addition, the non-standard region "smart:w,h" returns the most interesting
+region with the aspect ratio w:h.
+*/
+package iiif
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/internal/codec"
+	"github.com/muesli/smartcrop/internal/serve"
+	"github.com/muesli/smartcrop/nfnt"
+	"github.com/muesli/smartcrop/options"
+)
+
+const (
+	// DefaultMaxPixels is the default limit of the pixel count of source
+	// images and of responses.
+	DefaultMaxPixels = serve.DefaultMaxPixels
+	// DefaultMaxAge is the default lifetime of responses in caches.
+	DefaultMaxAge = serve.DefaultMaxAge
+
+	contextURI  = "http://iiif.io/api/image/3/context.json"
+	protocol    = "http://iiif.io/api/image"
+	profileURI  = "http://iiif.io/api/image/3/level1.json"
+	jpegQuality = 90
+)
+
+// formats maps the IIIF format names to our image formats.
+var formats = map[string]string{
+	"jpg": "jpeg",
+	"png": "png",
+	"gif": "gif",
+	"tif": "tiff",
+}
+
+// Handler is an http.Handler serving the images in a directory via the IIIF
+// Image API.
+type Handler struct {
+	// Root is the directory the images are stored in. If it is empty, no
+	// images are served.
+	Root string
+	// BaseURL is the URL the handler is reachable at, used for the ids in
+	// info.json. If empty, it is derived from the request, which only works
+	// if the handler is served at the root of the server.
+	BaseURL string
+	// Analyzer finds the smart regions.
+	Analyzer smartcrop.Analyzer
+	// Resizer scales the images.
+	Resizer options.Resizer
+	// MaxPixels limits the pixel count of source images and of responses.
+	MaxPixels int
+	// MaxAge is the lifetime of responses announced in the Cache-Control
+	// header.
+	MaxAge time.Duration
+}
+
+// NewHandler returns a Handler serving the images in root.
+func NewHandler(root string) *Handler {
+	resizer := nfnt.NewDefaultResizer()
+	return &Handler{
+		Root:      root,
+		Analyzer:  smartcrop.NewAnalyzer(resizer),
+		Resizer:   resizer,
+		MaxPixels: DefaultMaxPixels,
+		MaxAge:    DefaultMaxAge,
+	}
+}
+
+// info is the image information document, info.json.
+type info struct {
+	Context        string   `json:"@context"`
+	ID             string   `json:"id"`
+	Type           string   `json:"type"`
+	Protocol       string   `json:"protocol"`
+	Profile        string   `json:"profile"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	MaxArea        int      `json:"maxArea,omitempty"`
+	ExtraFormats   []string `json:"extraFormats"`
+	ExtraQualities []string `json:"extraQualities"`
+	ExtraFeatures  []string `json:"extraFeatures"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	segs := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	switch {
+	case len(segs) >= 2 && segs[len(segs)-1] == "info.json":
+		h.serveInfo(w, r, segs[:len(segs)-1])
+	case len(segs) >= 5:
+		params := make([]string, 4)
+		for i, p := range segs[len(segs)-4:] {
+			var err error
+			if params[i], err = url.PathUnescape(p); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		h.serveImage(w, r, segs[:len(segs)-4], params)
+	case len(segs) >= 1 && segs[0] != "":
+		// the base URI of an image redirects to its info.json
+		http.Redirect(w, r, h.id(r, segs)+"/info.json", http.StatusSeeOther)
+	default:
+		serve.Error(w, serve.ErrNotFound, http.StatusNotFound)
+	}
+}
+
+func (h *Handler) serveInfo(w http.ResponseWriter, r *http.Request, identifier []string) {
+	f, err := h.open(identifier)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		http.Error(w, "can't decode image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	doc := info{
+		Context:        contextURI,
+		ID:             h.id(r, identifier),
+		Type:           "ImageService3",
+		Protocol:       protocol,
+		Profile:        "level1",
+		Width:          cfg.Width,
+		Height:         cfg.Height,
+		MaxArea:        h.limits().Pixels(),
+		ExtraFormats:   []string{"png", "gif", "tif"},
+		ExtraQualities: []string{"color", "gray"},
+		ExtraFeatures: []string{
+			"mirroring", "regionByPct", "regionSquare", "rotationBy90s",
+			"sizeByConfinedWh", "sizeByPct", "sizeUpscaling",
+		},
+	}
+
+	ct := "application/json"
+	if strings.Contains(r.Header.Get("Accept"), "application/ld+json") {
+		ct = `application/ld+json;profile="` + contextURI + `"`
+	}
+	w.Header().Set("Content-Type", ct)
+	h.setCacheHeaders(w)
+	if r.Method != http.MethodHead {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(doc)
+	}
+}
+
+func (h *Handler) serveImage(w http.ResponseWriter, r *http.Request, identifier, params []string) {
+	qf := strings.SplitN(params[3], ".", 2)
+	if len(qf) != 2 {
+		http.Error(w, "missing format", http.StatusBadRequest)
+		return
+	}
+	format, ok := formats[qf[1]]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported format %q", qf[1]), http.StatusBadRequest)
+		return
+	}
+	q := qf[0]
+	if q != "default" && q != "color" && q != "gray" {
+		http.Error(w, fmt.Sprintf("unsupported quality %q", q), http.StatusBadRequest)
+		return
+	}
+	rot, err := parseRotation(params[2])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := h.load(identifier)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	region, err := h.region(img, params[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	width, height, err := parseSize(params[1], region.Dx(), region.Dy())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.limits().CheckSize(width, height); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	out := serve.SubImage(img, region)
+	if width != region.Dx() || height != region.Dy() {
+		out = serve.Resizer(h.Resizer).Resize(out, uint(width), uint(height))
+	}
+	out = rot.apply(out)
+	if q == "gray" {
+		out = toGray(out)
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, out, format, jpegQuality); err != nil {
+		http.Error(w, "can't encode image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", codec.ContentType(format))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Link", `<`+profileURI+`>;rel="profile"`)
+	h.setCacheHeaders(w)
+	if r.Method != http.MethodHead {
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// region parses the region parameter of a request for img.
+func (h *Handler) region(img image.Image, s string) (image.Rectangle, error) {
+	b := img.Bounds()
+
+	var r image.Rectangle
+	switch {
+	case s == "full":
+		return b, nil
+
+	case s == "square":
+		side := b.Dx()
+		if b.Dy() < side {
+			side = b.Dy()
+		}
+		return h.smartRegion(img, side, side)
+
+	case strings.HasPrefix(s, "smart:"):
+		v, err := parseFloats(strings.TrimPrefix(s, "smart:"), 2)
+		if err != nil || v[0] <= 0 || v[1] <= 0 {
+			return r, fmt.Errorf("invalid region %q", s)
+		}
+		// the analyzer only cares about the aspect ratio
+		w, hgt := b.Dx(), int(float64(b.Dx())*v[1]/v[0])
+		if hgt > b.Dy() {
+			w, hgt = int(float64(b.Dy())*v[0]/v[1]), b.Dy()
+		}
+		if w < 1 || hgt < 1 {
+			return r, fmt.Errorf("invalid region %q", s)
+		}
+		return h.smartRegion(img, w, hgt)
+
+	case strings.HasPrefix(s, "pct:"):
+		v, err := parseFloats(strings.TrimPrefix(s, "pct:"), 4)
+		if err != nil {
+			return r, fmt.Errorf("invalid region %q", s)
+		}
+		fx, fy := float64(b.Dx())/100, float64(b.Dy())/100
+		r = image.Rect(int(v[0]*fx), int(v[1]*fy), int((v[0]+v[2])*fx), int((v[1]+v[3])*fy))
+
+	default:
+		v, err := parseFloats(s, 4)
+		if err != nil {
+			return r, fmt.Errorf("invalid region %q", s)
+		}
+		r = image.Rect(int(v[0]), int(v[1]), int(v[0]+v[2]), int(v[1]+v[3]))
+	}
+
+	r = r.Add(b.Min).Intersect(b)
+	if r.Empty() {
+		return r, errors.New("region outside of the image")
+	}
+	return r, nil
+}
+
+func (h *Handler) smartRegion(img image.Image, width, height int) (image.Rectangle, error) {
+	analyzer := h.Analyzer
+	if analyzer == nil {
+		analyzer = smartcrop.NewAnalyzer(serve.Resizer(h.Resizer))
+	}
+	r, err := analyzer.FindBestCrop(img, width, height)
+	if err != nil {
+		return r, err
+	}
+	return r.Intersect(img.Bounds()), nil
+}
+
+// id returns the base URI of an image.
+func (h *Handler) id(r *http.Request, identifier []string) string {
+	base := strings.TrimSuffix(h.BaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/" + strings.Join(identifier, "/")
+}
+
+// open opens the image file an identifier refers to.
+func (h *Handler) open(identifier []string) (*os.File, error) {
+	if h.Root == "" {
+		return nil, serve.ErrNotFound
+	}
+	id, err := url.PathUnescape(strings.Join(identifier, "/"))
+	if err != nil {
+		return nil, serve.ErrNotFound
+	}
+	// cleaning the path as an absolute one strips any attempt to leave root
+	p := path.Clean("/" + id)
+	if p == "/" {
+		return nil, serve.ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(h.Root, filepath.FromSlash(p)))
+	if err != nil {
+		return nil, serve.ErrNotFound
+	}
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, serve.ErrNotFound
+	}
+	return f, nil
+}
+
+func (h *Handler) load(identifier []string) (image.Image, error) {
+	f, err := h.open(identifier)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // read-only file
+
+	img, _, err := h.limits().Decode(f)
+	return img, err
+}
+
+func (h *Handler) setCacheHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.limits().Age().Seconds())))
+}
+
+func (h *Handler) limits() serve.Limits {
+	return serve.Limits{MaxPixels: h.MaxPixels, MaxAge: h.MaxAge}
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	serve.Error(w, err, http.StatusInternalServerError)
+}
+
+// parseFloats parses n comma-separated non-negative numbers.
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d values", n)
+	}
+	v := make([]float64, n)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid value %q", p)
+		}
+		v[i] = f
+	}
+	return v, nil
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package iiif
+
+import (
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	h := NewHandler("../examples")
+	h.BaseURL = "https://example.org/iiif"
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/gopher.jpg/info.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var doc info
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != "https://example.org/iiif/gopher.jpg" || doc.Width != 900 || doc.Height != 284 {
+		t.Fatalf("unexpected info %+v", doc)
+	}
+}
+
+func TestEmptyRoot(t *testing.T) {
+	p, err := filepath.Abs("../examples/gopher.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty root must not resolve identifiers from the filesystem root
+	h := NewHandler("")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/"+url.PathEscape(filepath.ToSlash(p))+"/info.json", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestImage(t *testing.T) {
+	h := NewHandler("../examples")
+
+	tests := []struct {
+		url           string
+		status        int
+		width, height int
+	}{
+		{"/gopher.jpg/full/max/0/default.jpg", http.StatusOK, 900, 284},
+		{"/gopher.jpg/square/100,/0/default.png", http.StatusOK, 100, 100},
+		{"/gopher.jpg/smart:16,9/!320,320/0/gray.jpg", http.StatusOK, 320, 180},
+		{"/gopher.jpg/10,20,200,100/pct:50/90/default.jpg", http.StatusOK, 50, 100},
+		{"/gopher.jpg/pct:0,0,50,50/,71/!180/color.jpg", http.StatusOK, 225, 71},
+		{"/gopher.jpg/full/1000,/0/default.jpg", http.StatusBadRequest, 0, 0},
+		{"/gopher.jpg/full/^1000,/0/default.jpg", http.StatusOK, 1000, 316},
+		{"/gopher.jpg/full/max/45/default.jpg", http.StatusBadRequest, 0, 0},
+		{"/gopher.jpg/full/max/0/bitonal.jpg", http.StatusBadRequest, 0, 0},
+		{"/missing.jpg/full/max/0/default.jpg", http.StatusNotFound, 0, 0},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", test.url, nil))
+		if rec.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d: %s", test.url, test.status, rec.Code, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+
+		img, _, err := image.Decode(rec.Body)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		if img.Bounds().Dx() != test.width || img.Bounds().Dy() != test.height {
+			t.Errorf("%s: expected %dx%d, got %dx%d", test.url, test.width, test.height, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+}
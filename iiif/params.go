@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package iiif
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseSize returns the output dimensions for the size parameter s, applied
+// to a region of width x height pixels.
+func parseSize(s string, width, height int) (int, int, error) {
+	upscale := strings.HasPrefix(s, "^")
+	s = strings.TrimPrefix(s, "^")
+	invalid := fmt.Errorf("invalid size %q", s)
+
+	var w, h int
+	switch {
+	case s == "max":
+		w, h = width, height
+
+	case strings.HasPrefix(s, "pct:"):
+		pct, err := strconv.ParseFloat(strings.TrimPrefix(s, "pct:"), 64)
+		if err != nil || pct <= 0 {
+			return 0, 0, invalid
+		}
+		w = int(math.Round(float64(width) * pct / 100))
+		h = int(math.Round(float64(height) * pct / 100))
+
+	default:
+		confined := strings.HasPrefix(s, "!")
+		parts := strings.Split(strings.TrimPrefix(s, "!"), ",")
+		if len(parts) != 2 {
+			return 0, 0, invalid
+		}
+		var err error
+		if parts[0] != "" {
+			if w, err = strconv.Atoi(parts[0]); err != nil || w <= 0 {
+				return 0, 0, invalid
+			}
+		}
+		if parts[1] != "" {
+			if h, err = strconv.Atoi(parts[1]); err != nil || h <= 0 {
+				return 0, 0, invalid
+			}
+		}
+
+		switch {
+		case w == 0 && h == 0:
+			return 0, 0, invalid
+		case confined:
+			if w == 0 || h == 0 {
+				return 0, 0, invalid
+			}
+			scale := math.Min(float64(w)/float64(width), float64(h)/float64(height))
+			w = int(math.Round(float64(width) * scale))
+			h = int(math.Round(float64(height) * scale))
+		case h == 0:
+			h = int(math.Round(float64(height) * float64(w) / float64(width)))
+		case w == 0:
+			w = int(math.Round(float64(width) * float64(h) / float64(height)))
+		}
+	}
+
+	if w < 1 || h < 1 {
+		return 0, 0, invalid
+	}
+	if !upscale && (w > width || h > height) {
+		return 0, 0, errors.New("size exceeds the region, use ^ to allow upscaling")
+	}
+	return w, h, nil
+}
+
+// rotation is a parsed rotation parameter.
+type rotation struct {
+	mirror  bool
+	degrees int
+}
+
+func parseRotation(s string) (rotation, error) {
+	r := rotation{mirror: strings.HasPrefix(s, "!")}
+	d, err := strconv.ParseFloat(strings.TrimPrefix(s, "!"), 64)
+	if err != nil || d < 0 || d > 360 || math.Mod(d, 90) != 0 {
+		return r, fmt.Errorf("unsupported rotation %q, only multiples of 90 are supported", s)
+	}
+	r.degrees = int(d) % 360
+	return r, nil
+}
+
+// apply mirrors img if requested and then rotates it clockwise.
+func (r rotation) apply(img image.Image) image.Image {
+	if !r.mirror && r.degrees == 0 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if r.degrees == 90 || r.degrees == 270 {
+		dw, dh = h, w
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx := x
+			if r.mirror {
+				sx = w - 1 - x
+			}
+			c := color.RGBAModel.Convert(img.At(b.Min.X+sx, b.Min.Y+y))
+
+			var dx, dy int
+			switch r.degrees {
+			case 0:
+				dx, dy = x, y
+			case 90:
+				dx, dy = h-1-y, x
+			case 180:
+				dx, dy = w-1-x, h-1-y
+			case 270:
+				dx, dy = y, w-1-x
+			}
+			out.Set(dx, dy, c)
+		}
+	}
+	return out
+}
+
+func toGray(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(x, y, img.At(x, y))
+		}
+	}
+	return out
+}
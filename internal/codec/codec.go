@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+// Package codec bundles the image formats supported by the smartcrop tools.
+// Importing it registers the decoders for all of them with the image package.
+package codec
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	// registers the webp decoder, there is no encoder for it
+	_ "golang.org/x/image/webp"
+)
+
+// extensions maps file extensions to image format names, as returned by
+// image.Decode.
+var extensions = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".bmp":  "bmp",
+	".tif":  "tiff",
+	".tiff": "tiff",
+	".webp": "webp",
+}
+
+var contentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"bmp":  "image/bmp",
+	"tiff": "image/tiff",
+	"webp": "image/webp",
+}
+
+// encoders lists the formats we can write.
+var encoders = map[string]func(w io.Writer, img image.Image, quality int) error{
+	"jpeg": func(w io.Writer, img image.Image, quality int) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	},
+	"png": func(w io.Writer, img image.Image, _ int) error {
+		return png.Encode(w, img)
+	},
+	"gif": func(w io.Writer, img image.Image, _ int) error {
+		return gif.Encode(w, img, nil)
+	},
+	"bmp": func(w io.Writer, img image.Image, _ int) error {
+		return bmp.Encode(w, img)
+	},
+	"tiff": func(w io.Writer, img image.Image, _ int) error {
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	},
+}
+
+// FormatFromExtension returns the format belonging to a file extension like
+// ".jpg", or an empty string for unknown extensions.
+func FormatFromExtension(ext string) string {
+	return extensions[strings.ToLower(ext)]
+}
+
+// Extension returns the preferred file extension for format.
+func Extension(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// ContentType returns the MIME type of format.
+func ContentType(format string) string {
+	if t, ok := contentTypes[format]; ok {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// CanEncode reports whether images can be written in format.
+func CanEncode(format string) bool {
+	_, ok := encoders[format]
+	return ok
+}
+
+// Encode writes img to w in the given format. Quality is only used by formats
+// with lossy compression.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	encode, ok := encoders[format]
+	if !ok {
+		return fmt.Errorf("can't encode %s images", format)
+	}
+	return encode(w, img, quality)
+}
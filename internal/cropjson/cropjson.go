@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+// Package cropjson describes crops in the JSON format shared by the -json
+// output of the smartcrop command and the format=json responses of httpcrop.
+package cropjson
+
+import (
+	"image"
+
+	"github.com/muesli/smartcrop"
+)
+
+// Crop describes the chosen crop of an image. Coordinates are relative to the
+// origin of the image.
+type Crop struct {
+	X          int            `json:"x"`
+	Y          int            `json:"y"`
+	Width      int            `json:"width"`
+	Height     int            `json:"height"`
+	Normalized NormalizedRect `json:"normalized"`
+	Source     Dimensions     `json:"source"`
+	Target     Dimensions     `json:"target"`
+	Score      *Score         `json:"score,omitempty"`
+}
+
+// NormalizedRect is a rectangle relative to the source dimensions, with all
+// values ranging from 0 to 1.
+type NormalizedRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Dimensions are the width and height of an image.
+type Dimensions struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Score is the score of a crop, see smartcrop.Score.
+type Score struct {
+	Detail     float64 `json:"detail"`
+	Saturation float64 `json:"saturation"`
+	Skin       float64 `json:"skin"`
+	Boost      float64 `json:"boost"`
+	Extra      float64 `json:"extra"`
+	Total      float64 `json:"total"`
+}
+
+// Describe returns the description of crop c, chosen for the target
+// dimensions width x height within an image with the given bounds. The score
+// is left out if c has none, like the crops of analyzers which don't
+// implement smartcrop.CropsAnalyzer.
+func Describe(bounds image.Rectangle, c smartcrop.Crop, width, height int) Crop {
+	res := New(bounds, c.Rectangle)
+	res.Target = Dimensions{Width: width, Height: height}
+	if c.Score == (smartcrop.Score{}) {
+		return res
+	}
+	res.Score = &Score{
+		Detail:     c.Score.Detail,
+		Saturation: c.Score.Saturation,
+		Skin:       c.Score.Skin,
+		Boost:      c.Score.Boost,
+		Extra:      c.Score.Extra,
+		Total:      c.Score.Total,
+	}
+	return res
+}
+
+// New returns the description of crop r within an image with the given
+// bounds, without target dimensions and score.
+func New(bounds, r image.Rectangle) Crop {
+	r = r.Sub(bounds.Min)
+	sw, sh := float64(bounds.Dx()), float64(bounds.Dy())
+
+	return Crop{
+		X:      r.Min.X,
+		Y:      r.Min.Y,
+		Width:  r.Dx(),
+		Height: r.Dy(),
+		Normalized: NormalizedRect{
+			X:      float64(r.Min.X) / sw,
+			Y:      float64(r.Min.Y) / sh,
+			Width:  float64(r.Dx()) / sw,
+			Height: float64(r.Dy()) / sh,
+		},
+		Source: Dimensions{Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+}
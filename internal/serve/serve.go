@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+// Package serve holds what the HTTP handlers of smartcrop share: the limits
+// guarding the server against huge images, loading images within them and
+// mapping errors to responses.
+package serve
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/muesli/smartcrop/nfnt"
+	"github.com/muesli/smartcrop/options"
+)
+
+const (
+	// DefaultMaxBytes is the default size limit of source images.
+	DefaultMaxBytes = 32 << 20
+	// DefaultMaxPixels is the default limit of the pixel count of source
+	// images and of responses.
+	DefaultMaxPixels = 50 * 1000 * 1000
+	// DefaultMaxAge is the default lifetime of responses in caches.
+	DefaultMaxAge = 24 * time.Hour
+)
+
+var (
+	// ErrTooLarge gets returned for images exceeding a limit.
+	ErrTooLarge = errors.New("image too large")
+	// ErrNotFound gets returned for images which don't exist.
+	ErrNotFound = errors.New("image not found")
+)
+
+// Limits are the limits of a handler. Zero values select the defaults.
+type Limits struct {
+	MaxBytes  int64
+	MaxPixels int
+	MaxAge    time.Duration
+}
+
+// Bytes returns the size limit of source images.
+func (l Limits) Bytes() int64 {
+	if l.MaxBytes <= 0 {
+		return DefaultMaxBytes
+	}
+	return l.MaxBytes
+}
+
+// Pixels returns the limit of the pixel count of source images and responses.
+func (l Limits) Pixels() int {
+	if l.MaxPixels <= 0 {
+		return DefaultMaxPixels
+	}
+	return l.MaxPixels
+}
+
+// Age returns the lifetime of responses in caches.
+func (l Limits) Age() time.Duration {
+	if l.MaxAge <= 0 {
+		return DefaultMaxAge
+	}
+	return l.MaxAge
+}
+
+// CheckSize returns ErrTooLarge if an image of width x height exceeds the
+// pixel limit. Every response needs to be checked before it gets allocated,
+// as a small source can be scaled up to a huge one.
+func (l Limits) CheckSize(width, height int) error {
+	if width > 0 && height > l.Pixels()/width {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Read reads all of r, or returns ErrTooLarge once it exceeds the size limit.
+func (l Limits) Read(r io.Reader) ([]byte, error) {
+	limit := l.Bytes()
+	b, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, ErrTooLarge
+	}
+	return b, nil
+}
+
+// Decode decodes the image read from r. Its dimensions get checked against the
+// pixel limit before the pixels get decoded.
+func (l Limits) Decode(r io.ReadSeeker) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't decode image: %v", err)
+	}
+	if err := l.CheckSize(cfg.Width, cfg.Height); err != nil {
+		return nil, "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't decode image: %v", err)
+	}
+	return img, format, nil
+}
+
+// Load reads r within the size limit and decodes it within the pixel limit.
+func (l Limits) Load(r io.Reader) (image.Image, string, error) {
+	b, err := l.Read(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return l.Decode(bytes.NewReader(b))
+}
+
+// Resizer returns r, or the default resizer if r is nil.
+func Resizer(r options.Resizer) options.Resizer {
+	if r == nil {
+		return nfnt.NewDefaultResizer()
+	}
+	return r
+}
+
+// SubImage returns the part r of img, sharing its pixels if possible.
+func SubImage(img image.Image, r image.Rectangle) image.Image {
+	type SubImager interface {
+		SubImage(r image.Rectangle) image.Image
+	}
+	if sub, ok := img.(SubImager); ok {
+		return sub.SubImage(r)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			out.Set(x-r.Min.X, y-r.Min.Y, img.At(x, y))
+		}
+	}
+	return out
+}
+
+// Error replies with err and the status it calls for, or with status if err
+// isn't one of the errors of this package.
+func Error(w http.ResponseWriter, err error, status int) {
+	switch err {
+	case ErrNotFound:
+		status = http.StatusNotFound
+	case ErrTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	}
+	http.Error(w, err.Error(), status)
+}
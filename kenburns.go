@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// smallest zoom of the windows of a Ken Burns path
+	kenBurnsMinScale = 0.6
+	// minimum distance between the centres of the start and end window,
+	// relative to the image diagonal
+	kenBurnsMinDistance = 0.15
+)
+
+// KenBurnsAnalyzer is implemented by analyzers which can find a pan-and-zoom
+// path between two areas of interest
+type KenBurnsAnalyzer interface {
+	Analyzer
+	FindKenBurns(img image.Image, width, height int) (KenBurns, error)
+}
+
+// Easing maps the progress of a transition, from 0 to 1, to the progress of
+// the movement.
+type Easing func(t float64) float64
+
+// EaseLinear moves at a constant speed.
+func EaseLinear(t float64) float64 {
+	return t
+}
+
+// EaseInOut starts slowly, accelerates and slows down towards the end.
+func EaseInOut(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// KenBurns is a pan-and-zoom path from the Start to the End window.
+type KenBurns struct {
+	Start  image.Rectangle
+	End    image.Rectangle
+	Easing Easing
+}
+
+// Frame returns the window at t, ranging from 0 for the start to 1 for the
+// end of the path. A nil Easing moves linearly.
+func (k KenBurns) Frame(t float64) image.Rectangle {
+	t = math.Min(math.Max(t, 0), 1)
+	if k.Easing != nil {
+		t = k.Easing(t)
+	}
+
+	lerp := func(a, b int) int {
+		return int(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return image.Rect(
+		lerp(k.Start.Min.X, k.End.Min.X),
+		lerp(k.Start.Min.Y, k.End.Min.Y),
+		lerp(k.Start.Max.X, k.End.Max.X),
+		lerp(k.Start.Max.Y, k.End.Max.Y),
+	)
+}
+
+// FindKenBurns returns a path starting at the best crop and ending at the best
+// crop showing another area of the image, both with the aspect ratio of width x
+// height. If the image has no second area of interest, the path zooms out to
+// the best of the largest crops instead. The path eases in and out. Boosts and
+// the text mode apply as they do to FindBestCrops.
+func (o smartcropAnalyzer) FindKenBurns(img image.Image, width, height int) (KenBurns, error) {
+	if width == 0 && height == 0 {
+		return KenBurns{}, ErrInvalidDimensions
+	}
+
+	scale := math.Min(float64(img.Bounds().Dx())/float64(width), float64(img.Bounds().Dy())/float64(height))
+	features, extraMap, prescalefactor := o.features([]image.Image{img})
+	textBoosts, textBlocks := o.textBoosts(img, prescalefactor)
+	boostMap := makeBoostMap(features, append(textBoosts, o.boosts...), img.Bounds().Min, prescalefactor)
+
+	cropWidth, cropHeight := chop(float64(width)*scale*prescalefactor), chop(float64(height)*scale*prescalefactor)
+	cs := analyse(o.logger, features, boostMap, extraMap, cropWidth, cropHeight, kenBurnsMinScale, 0)
+	cs = unsplit(cs, textBlocks)
+	upscale(cs, prescalefactor, img.Bounds().Min)
+
+	start := cs[0]
+	minDistance := kenBurnsMinDistance * math.Hypot(float64(img.Bounds().Dx()), float64(img.Bounds().Dy()))
+	var end *Crop
+	for i, c := range cs[1:] {
+		if distance(start.Rectangle, c.Rectangle) >= minDistance {
+			end = &cs[i+1]
+			break
+		}
+	}
+	if end == nil {
+		// zoom out to the best of the largest crops
+		for i, c := range cs {
+			if c.Dx() > start.Dx() {
+				end = &cs[i]
+				break
+			}
+		}
+	}
+	if end == nil {
+		end = &start
+	}
+
+	return KenBurns{
+		Start:  start.Rectangle,
+		End:    end.Rectangle,
+		Easing: EaseInOut,
+	}, nil
+}
+
+// distance returns the distance between the centres of a and b.
+func distance(a, b image.Rectangle) float64 {
+	return math.Hypot(
+		float64(a.Min.X+a.Max.X-b.Min.X-b.Max.X)/2,
+		float64(a.Min.Y+a.Max.Y-b.Min.Y-b.Max.Y)/2,
+	)
+}
@@ -0,0 +1,373 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+)
+
+const (
+	// size of the square image the spectral residual gets computed on,
+	// must be a power of two
+	spectralSize = 64
+	// standard deviation of the blur applied to the saliency map
+	spectralBlur = 2.5
+)
+
+// SpectralResidual is a Detector finding visually salient regions with the
+// spectral residual approach by Hou and Zhang: the log amplitude spectrum of an
+// image is compared to its local average, and whatever deviates from it, like
+// an object on a busy but uniform background, gets marked as salient.
+type SpectralResidual struct{}
+
+// Detect returns the saliency of every pixel of img, ranging from 0 to 1.
+func (SpectralResidual) Detect(img *image.RGBA) []float64 {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	if width == 0 || height == 0 {
+		return make([]float64, width*height)
+	}
+
+	// the spectrum of a small grayscale version is sufficient
+	small := shrink(makeCies(img), width, height, spectralSize, spectralSize)
+	spectrum := make([]complex128, len(small))
+	for i, v := range small {
+		spectrum[i] = complex(v/255, 0)
+	}
+	fft2(spectrum, spectralSize, false)
+
+	amplitude := make([]float64, len(spectrum))
+	for i, c := range spectrum {
+		amplitude[i] = math.Log(cmplx.Abs(c) + 1e-9)
+	}
+	// the strongest frequencies lie along the edges of the spectrum, so their
+	// neighbourhoods repeat the edge instead of being cut off
+	padded := padEdges(amplitude, spectralSize, spectralSize, 1)
+	average := boxMean(padded, spectralSize+2, spectralSize+2, 1)
+	for i, c := range spectrum {
+		// keep the phase, replace the amplitude by the spectral residual
+		y, x := i/spectralSize, i%spectralSize
+		residual := amplitude[i] - average[(y+1)*(spectralSize+2)+x+1]
+		spectrum[i] = cmplx.Rect(math.Exp(residual), cmplx.Phase(c))
+	}
+	fft2(spectrum, spectralSize, true)
+
+	saliency := make([]float64, len(spectrum))
+	for i, c := range spectrum {
+		a := cmplx.Abs(c)
+		saliency[i] = a * a
+	}
+	saliency = gaussianBlur(saliency, spectralSize, spectralSize, spectralBlur)
+	normalize(saliency)
+
+	return enlarge(saliency, spectralSize, spectralSize, width, height)
+}
+
+// ColorContrast is a Detector finding regions whose colour stands out, in the
+// spirit of the frequency-tuned saliency by Achanta et al.: pixels get marked
+// by how much their colour in CIELAB differs from the mean colour of the image
+// and from the colour of their surroundings. Unlike the built-in saturation
+// detection, a grey object on a vivid background is salient as well.
+type ColorContrast struct{}
+
+// Detect returns the colour contrast of every pixel of img, ranging from 0
+// to 1.
+func (ColorContrast) Detect(img *image.RGBA) []float64 {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	if width == 0 || height == 0 {
+		return make([]float64, width*height)
+	}
+
+	var lab [3][]float64
+	for c := range lab {
+		lab[c] = make([]float64, width*height)
+	}
+	var mean [3]float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			lab[0][i], lab[1][i], lab[2][i] = toLab(img.RGBAAt(img.Bounds().Min.X+x, img.Bounds().Min.Y+y))
+			for c := range lab {
+				mean[c] += lab[c][i]
+			}
+		}
+	}
+
+	// a slight blur suppresses noise and texture, the surroundings are the
+	// average of a neighbourhood an eighth of the image in size
+	radius := int(math.Max(1, math.Min(float64(width), float64(height))/16))
+	var blurred, surround [3][]float64
+	for c := range lab {
+		mean[c] /= float64(width * height)
+		blurred[c] = gaussianBlur(lab[c], width, height, 1)
+		surround[c] = boxMean(lab[c], width, height, radius)
+	}
+
+	contrast := make([]float64, width*height)
+	for i := range contrast {
+		var global, local float64
+		for c := range lab {
+			d := blurred[c][i] - mean[c]
+			global += d * d
+			d = blurred[c][i] - surround[c][i]
+			local += d * d
+		}
+		contrast[i] = (math.Sqrt(global) + math.Sqrt(local)) / 2
+	}
+	normalize(contrast)
+
+	return contrast
+}
+
+// toLab converts an sRGB colour to CIELAB, using the D65 white point.
+func toLab(c color.RGBA) (float64, float64, float64) {
+	linear := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(c.R), linear(c.G), linear(c.B)
+
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// boxMean returns the average of the (2*radius+1)^2 neighbourhood of every
+// value of the plane, computed in constant time per value from a summed-area
+// table. Neighbourhoods get cut off at the edges.
+func boxMean(plane []float64, width, height, radius int) []float64 {
+	sums := make([]float64, (width+1)*(height+1))
+	for y := 0; y < height; y++ {
+		var row float64
+		for x := 0; x < width; x++ {
+			row += plane[y*width+x]
+			sums[(y+1)*(width+1)+x+1] = sums[y*(width+1)+x+1] + row
+		}
+	}
+
+	out := make([]float64, len(plane))
+	for y := 0; y < height; y++ {
+		y0, y1 := clamp(y-radius, 0, height), clamp(y+radius+1, 0, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := clamp(x-radius, 0, width), clamp(x+radius+1, 0, width)
+			sum := sums[y1*(width+1)+x1] - sums[y0*(width+1)+x1] - sums[y1*(width+1)+x0] + sums[y0*(width+1)+x0]
+			out[y*width+x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return out
+}
+
+// padEdges returns the plane with a border of radius values on every side,
+// repeating the values along its edges.
+func padEdges(plane []float64, width, height, radius int) []float64 {
+	w, h := width+2*radius, height+2*radius
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out[y*w+x] = plane[clamp(y-radius, 0, height-1)*width+clamp(x-radius, 0, width-1)]
+		}
+	}
+	return out
+}
+
+// fft2 transforms the size x size values in place, or transforms them back if
+// inverse is set.
+func fft2(values []complex128, size int, inverse bool) {
+	column := make([]complex128, size)
+	for y := 0; y < size; y++ {
+		fft(values[y*size:(y+1)*size], inverse)
+	}
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			column[y] = values[y*size+x]
+		}
+		fft(column, inverse)
+		for y := 0; y < size; y++ {
+			values[y*size+x] = column[y]
+		}
+	}
+}
+
+// fft is an iterative radix-2 fast Fourier transform. The length of values
+// must be a power of two. The inverse transform is scaled by 1/n.
+func fft(values []complex128, inverse bool) {
+	n := len(values)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+
+	for length := 2; length <= n; length <<= 1 {
+		angle := 2 * math.Pi / float64(length)
+		if !inverse {
+			angle = -angle
+		}
+		w := cmplx.Rect(1, angle)
+		for i := 0; i < n; i += length {
+			wn := complex(1, 0)
+			for j := 0; j < length/2; j++ {
+				u := values[i+j]
+				v := values[i+j+length/2] * wn
+				values[i+j] = u + v
+				values[i+j+length/2] = u - v
+				wn *= w
+			}
+		}
+	}
+
+	if inverse {
+		for i := range values {
+			values[i] /= complex(float64(n), 0)
+		}
+	}
+}
+
+// shrink scales the plane down to dw x dh by averaging.
+func shrink(plane []float64, width, height, dw, dh int) []float64 {
+	out := make([]float64, dw*dh)
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*height/dh, (y+1)*height/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*width/dw, (x+1)*width/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum += plane[sy*width+sx]
+				}
+			}
+			out[y*dw+x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return out
+}
+
+// enlarge scales the plane up to dw x dh with bilinear interpolation.
+func enlarge(plane []float64, width, height, dw, dh int) []float64 {
+	out := make([]float64, dw*dh)
+	for y := 0; y < dh; y++ {
+		fy := math.Max(0, (float64(y)+0.5)*float64(height)/float64(dh)-0.5)
+		y0 := int(fy)
+		y1 := y0 + 1
+		if y1 >= height {
+			y1 = height - 1
+		}
+		ty := fy - float64(y0)
+		for x := 0; x < dw; x++ {
+			fx := math.Max(0, (float64(x)+0.5)*float64(width)/float64(dw)-0.5)
+			x0 := int(fx)
+			x1 := x0 + 1
+			if x1 >= width {
+				x1 = width - 1
+			}
+			tx := fx - float64(x0)
+
+			top := plane[y0*width+x0]*(1-tx) + plane[y0*width+x1]*tx
+			bottom := plane[y1*width+x0]*(1-tx) + plane[y1*width+x1]*tx
+			out[y*dw+x] = top*(1-ty) + bottom*ty
+		}
+	}
+	return out
+}
+
+// gaussianBlur blurs the plane with a Gaussian of the standard deviation
+// sigma. Values beyond the edges repeat the edge.
+func gaussianBlur(plane []float64, width, height int, sigma float64) []float64 {
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	tmp := make([]float64, len(plane))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var v float64
+			for i, k := range kernel {
+				v += plane[y*width+clamp(x+i-radius, 0, width-1)] * k
+			}
+			tmp[y*width+x] = v
+		}
+	}
+	out := make([]float64, len(plane))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var v float64
+			for i, k := range kernel {
+				v += tmp[clamp(y+i-radius, 0, height-1)*width+x] * k
+			}
+			out[y*width+x] = v
+		}
+	}
+	return out
+}
+
+// normalize scales the plane in place so its maximum is 1.
+func normalize(plane []float64) {
+	var limit float64
+	for _, v := range plane {
+		limit = math.Max(limit, v)
+	}
+	if limit == 0 {
+		return
+	}
+	for i := range plane {
+		plane[i] /= limit
+	}
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"image"
+	"math"
+
+	"github.com/muesli/smartcrop/options"
+)
+
+const (
+	defaultMaxPan     = 0.01
+	defaultHysteresis = 0.05
+	defaultSceneCut   = 0.5
+	// weight of the best crop of the latest frame in the running average the
+	// window follows
+	sequenceSmoothing = 0.3
+)
+
+// SequenceOptions configure a SequenceAnalyzer. Zero values select the
+// defaults.
+type SequenceOptions struct {
+	// MaxPan is the maximum distance the window moves per frame, relative to
+	// the frame width. Defaults to 0.01.
+	MaxPan float64
+	// Hysteresis is the distance, relative to the frame width, the best crop
+	// has to move away from the window before the window starts following
+	// it. Defaults to 0.05.
+	Hysteresis float64
+	// SceneCut is the difference of the features of two consecutive frames,
+	// from 0 for identical to 1 for nothing in common, above which a new
+	// scene starts and the window jumps to the best crop right away.
+	// Defaults to 0.5, values above 1 disable scene cut detection.
+	SceneCut float64
+}
+
+// SequenceCrop is the crop of a frame of a sequence.
+type SequenceCrop struct {
+	image.Rectangle
+	// SceneCut is set on the first frame of a scene, which includes the
+	// first frame of the sequence.
+	SceneCut bool
+}
+
+// SequenceAnalyzer finds crops for the consecutive frames of a video. Instead
+// of jumping to the best crop of every frame, the window behaves like a steady
+// camera operator: it only follows the content once it moved far enough, pans
+// with a limited speed and only cuts along with the scene.
+type SequenceAnalyzer struct {
+	analyzer smartcropAnalyzer
+	width    int
+	height   int
+	opts     SequenceOptions
+
+	bounds image.Rectangle
+	blocks []float64
+	target point
+	pos    point
+	moving bool
+}
+
+type point struct {
+	x, y float64
+}
+
+// NewSequenceAnalyzer returns a SequenceAnalyzer finding crops with the aspect
+// ratio of width x height, which are as large as the frames permit.
+func NewSequenceAnalyzer(resizer options.Resizer, width, height int, opts SequenceOptions) *SequenceAnalyzer {
+	a := NewAnalyzer(resizer).(*smartcropAnalyzer)
+	return &SequenceAnalyzer{
+		analyzer: *a,
+		width:    width,
+		height:   height,
+		opts:     opts,
+	}
+}
+
+// Next analyzes the next frame of the sequence and returns its crop.
+func (s *SequenceAnalyzer) Next(frame image.Image) (SequenceCrop, error) {
+	width, height := s.width, s.height
+	if width == 0 && height == 0 {
+		return SequenceCrop{}, ErrInvalidDimensions
+	}
+	if width == 0 || height == 0 {
+		// a missing dimension means a square crop
+		width, height = 1, 1
+	}
+
+	bounds := frame.Bounds()
+	features, extraMap, prescalefactor := s.analyzer.features([]image.Image{frame})
+	blocks := featureBlocks(features)
+	cut := s.blocks == nil || bounds != s.bounds || featureDifference(s.blocks, blocks) > s.sceneCut()
+	s.bounds, s.blocks = bounds, blocks
+
+	// the window always has the largest size fitting into the frame
+	scale := math.Min(float64(bounds.Dx())/float64(width), float64(bounds.Dy())/float64(height))
+	cropWidth, cropHeight := chop(float64(width)*scale*prescalefactor), chop(float64(height)*scale*prescalefactor)
+	best := analyse(s.analyzer.logger, features, nil, extraMap, cropWidth, cropHeight, maxScale, 1)
+	// the window is tracked relative to the frame and moved to its bounds
+	// once it's placed
+	upscale(best, prescalefactor, image.Point{})
+	centre := point{
+		x: float64(best[0].Min.X+best[0].Max.X) / 2,
+		y: float64(best[0].Min.Y+best[0].Max.Y) / 2,
+	}
+
+	if cut {
+		s.target, s.pos, s.moving = centre, centre, false
+	} else {
+		s.target.x += (centre.x - s.target.x) * sequenceSmoothing
+		s.target.y += (centre.y - s.target.y) * sequenceSmoothing
+		s.follow(float64(bounds.Dx()))
+	}
+
+	size := image.Pt(int(chop(float64(width)*scale)), int(chop(float64(height)*scale)))
+	origin := image.Pt(
+		clamp(int(math.Round(s.pos.x-float64(size.X)/2)), 0, bounds.Dx()-size.X),
+		clamp(int(math.Round(s.pos.y-float64(size.Y)/2)), 0, bounds.Dy()-size.Y),
+	)
+	return SequenceCrop{
+		Rectangle: image.Rectangle{Min: origin, Max: origin.Add(size)}.Add(bounds.Min),
+		SceneCut:  cut,
+	}, nil
+}
+
+// follow moves the window towards the target, once it is further away than
+// the hysteresis, at most by the maximum pan distance.
+func (s *SequenceAnalyzer) follow(frameWidth float64) {
+	dx, dy := s.target.x-s.pos.x, s.target.y-s.pos.y
+	d := math.Sqrt(dx*dx + dy*dy)
+	if !s.moving && d > s.hysteresis()*frameWidth {
+		s.moving = true
+	}
+	if !s.moving {
+		return
+	}
+
+	if step := s.maxPan() * frameWidth; d > step {
+		s.pos.x += dx * step / d
+		s.pos.y += dy * step / d
+		return
+	}
+	s.pos = s.target
+	s.moving = false
+}
+
+func (s *SequenceAnalyzer) maxPan() float64 {
+	if s.opts.MaxPan <= 0 {
+		return defaultMaxPan
+	}
+	return s.opts.MaxPan
+}
+
+func (s *SequenceAnalyzer) hysteresis() float64 {
+	if s.opts.Hysteresis <= 0 {
+		return defaultHysteresis
+	}
+	return s.opts.Hysteresis
+}
+
+func (s *SequenceAnalyzer) sceneCut() float64 {
+	if s.opts.SceneCut <= 0 {
+		return defaultSceneCut
+	}
+	return s.opts.SceneCut
+}
+
+// featureBlocks returns the average skin, detail and saturation of blocks of
+// the feature map o. Comparing blocks rather than pixels makes the scene cut
+// detection tolerate motion within a scene.
+func featureBlocks(o *image.RGBA) []float64 {
+	width := o.Bounds().Dx()
+	height := o.Bounds().Dy()
+	bw := (width + scoreDownSample - 1) / scoreDownSample
+	bh := (height + scoreDownSample - 1) / scoreDownSample
+
+	blocks := make([]float64, bw*bh*3)
+	counts := make([]float64, bw*bh)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := o.RGBAAt(x, y)
+			b := y/scoreDownSample*bw + x/scoreDownSample
+			blocks[b*3] += float64(c.R)
+			blocks[b*3+1] += float64(c.G)
+			blocks[b*3+2] += float64(c.B)
+			counts[b]++
+		}
+	}
+	for i := range blocks {
+		blocks[i] /= counts[i/3] * 255
+	}
+	return blocks
+}
+
+// featureDifference returns how much the feature blocks a and b differ, from
+// 0 for identical to 1 for no overlap at all.
+func featureDifference(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 1
+	}
+
+	var diff, sum float64
+	for i := range a {
+		diff += math.Abs(a[i] - b[i])
+		sum += a[i] + b[i]
+	}
+	if sum == 0 {
+		return 0
+	}
+	return diff / sum
+}
+
+func clamp(v, lo, hi int) int {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
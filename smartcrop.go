@@ -28,16 +28,35 @@
 /*
 Package smartcrop implements a content aware image cropping library based on
 Jonas Wagner's smartcrop.js https://github.com/jwagner/smartcrop.js
+
+The Analyzer returned by NewAnalyzer scores crops by the skin tones, details
+and saturation they contain. It implements optional interfaces offering
+further abilities, which callers get to with a type assertion:
+
+	BoostAnalyzer       takes hints about important or unwanted regions
+	DetectorAnalyzer    adds detectors like SpectralResidual or FocusDetector
+	TextAnalyzer        keeps text blocks whole inside or outside of the crop
+	CropsAnalyzer       reports the scores of the best crops
+	AnimationAnalyzer   finds a single crop for all frames of an animation
+	FrameAnalyzer       picks the best frame out of a set of candidates
+	FocalPointAnalyzer  finds the focal point of an image
+	KenBurnsAnalyzer    finds a pan-and-zoom path between areas of interest
+
+A SequenceAnalyzer crops the frames of a video without jitter, a Batch crops
+many images concurrently, and FindBestGIFCrop and CropGIF crop animated GIFs.
+NewStrategyAnalyzer returns analyzers behaving like the strategies of libvips.
 */
 package smartcrop
 
 import (
+	"container/heap"
 	"errors"
 	"image"
 	"image/color"
 	"io/ioutil"
 	"log"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/muesli/smartcrop/options"
@@ -64,6 +83,7 @@ const (
 	saturationThreshold     = 0.4
 	saturationBias          = 0.2
 	saturationWeight        = 0.3
+	boostWeight             = 100.0
 	scoreDownSample         = 8 // step * minscale rounded down to the next power of two should be good
 	step                    = 8
 	scaleStep               = 0.1
@@ -83,11 +103,59 @@ type Analyzer interface {
 	FindBestCrop(img image.Image, width, height int) (image.Rectangle, error)
 }
 
+// CropsAnalyzer is implemented by analyzers which, besides the best crop, can
+// report the scores of the best crops they considered
+type CropsAnalyzer interface {
+	Analyzer
+	FindBestCrops(img image.Image, width, height, count int) ([]Crop, error)
+}
+
+// BoostAnalyzer is implemented by analyzers which can take hints about the
+// importance of image regions into account
+type BoostAnalyzer interface {
+	Analyzer
+	WithBoosts(boosts []Boost) Analyzer
+}
+
+// DetectorAnalyzer is implemented by analyzers which can take additional
+// detectors into account
+type DetectorAnalyzer interface {
+	Analyzer
+	WithDetector(d Detector, weight float64) Analyzer
+}
+
+// Detector finds important regions of an image, in addition to the built-in
+// detection of skin, details and saturation.
+type Detector interface {
+	// Detect returns the importance of every pixel of img in row-major
+	// order, usually ranging from 0 to 1. Negative values mark pixels which
+	// should rather be left out of the crop.
+	Detect(img *image.RGBA) []float64
+}
+
+// laplacianDetector is implemented by detectors working on the Laplacian of
+// the lightness, which the analyzer shares with its edge detection.
+type laplacianDetector interface {
+	detectLaplacian(l []float64, width, height int) []float64
+}
+
+// Boost marks a region of the image as important, e.g. a face found by a
+// separate detector. A negative weight marks a region that should rather be
+// left out of the crop, like a watermark.
+type Boost struct {
+	image.Rectangle
+	Weight float64
+}
+
 // Score contains values that classify matches
 type Score struct {
 	Detail     float64
 	Saturation float64
 	Skin       float64
+	Boost      float64
+	// Extra is the weighted score of the additional detectors.
+	Extra float64
+	Total float64
 }
 
 // Crop contains results
@@ -103,10 +171,18 @@ type Logger struct {
 }
 
 type smartcropAnalyzer struct {
-	logger Logger
+	logger    Logger
+	boosts    []Boost
+	detectors []weightedDetector
+	textMode  TextMode
 	options.Resizer
 }
 
+type weightedDetector struct {
+	Detector
+	weight float64
+}
+
 // NewAnalyzer returns a new Analyzer using the given Resizer.
 func NewAnalyzer(resizer options.Resizer) Analyzer {
 	logger := Logger{
@@ -124,14 +200,163 @@ func NewAnalyzerWithLogger(resizer options.Resizer, logger Logger) Analyzer {
 	return &smartcropAnalyzer{Resizer: resizer, logger: logger}
 }
 
+// WithBoosts returns a copy of the analyzer which takes the given boosts into
+// account. Their rectangles are relative to the analyzed image.
+func (o smartcropAnalyzer) WithBoosts(boosts []Boost) Analyzer {
+	o.boosts = boosts
+	return &o
+}
+
+// WithDetector returns a copy of the analyzer which additionally scores crops
+// by the importance found by d, multiplied by weight.
+func (o smartcropAnalyzer) WithDetector(d Detector, weight float64) Analyzer {
+	o.detectors = append(append([]weightedDetector(nil), o.detectors...), weightedDetector{d, weight})
+	return &o
+}
+
 func (o smartcropAnalyzer) FindBestCrop(img image.Image, width, height int) (image.Rectangle, error) {
-	if width == 0 && height == 0 {
-		return image.Rectangle{}, ErrInvalidDimensions
+	crops, err := o.FindBestCrops(img, width, height, 1)
+	if err != nil {
+		return image.Rectangle{}, err
 	}
+	return crops[0].Rectangle, nil
+}
 
-	// resize image for faster processing
+// FindBestCrops returns up to count crops, sorted by their total score with the
+// best crop first. The rectangles are in the coordinate space of img, so they
+// can be passed to its SubImage method even if its bounds don't start at (0, 0).
+func (o smartcropAnalyzer) FindBestCrops(img image.Image, width, height, count int) ([]Crop, error) {
+	return o.findBestCrops([]image.Image{img}, width, height, count)
+}
+
+// findBestCrops scores the crops on the averaged features of all frames, which
+// must share the same bounds.
+func (o smartcropAnalyzer) findBestCrops(frames []image.Image, width, height, count int) ([]Crop, error) {
+	if width == 0 && height == 0 {
+		return nil, ErrInvalidDimensions
+	}
+	if count < 1 {
+		count = 1
+	}
+	img := frames[0]
 	scale := math.Min(float64(img.Bounds().Dx())/float64(width), float64(img.Bounds().Dy())/float64(height))
-	var lowimg *image.RGBA
+	features, extraMap, prescalefactor := o.features(frames)
+
+	cropWidth, cropHeight := chop(float64(width)*scale*prescalefactor), chop(float64(height)*scale*prescalefactor)
+	realMinScale := math.Min(maxScale, math.Max(1.0/scale, minScale))
+
+	o.logger.Log.Printf("original resolution: %dx%d\n", img.Bounds().Dx(), img.Bounds().Dy())
+	o.logger.Log.Printf("scale: %f, cropw: %f, croph: %f, minscale: %f\n", scale, cropWidth, cropHeight, realMinScale)
+
+	textBoosts, textBlocks := o.textBoosts(img, prescalefactor)
+	boostMap := makeBoostMap(features, append(textBoosts, o.boosts...), img.Bounds().Min, prescalefactor)
+
+	// crops splitting text get filtered out afterwards, so all of them are
+	// needed then
+	n := count
+	if len(textBlocks) > 0 {
+		n = 0
+	}
+	topCrops := analyse(o.logger, features, boostMap, extraMap, cropWidth, cropHeight, realMinScale, n)
+	topCrops = unsplit(topCrops, textBlocks)
+	if len(topCrops) > count {
+		topCrops = topCrops[:count]
+	}
+	upscale(topCrops, prescalefactor, img.Bounds().Min)
+
+	return topCrops, nil
+}
+
+// features returns the feature map and the importance found by the additional
+// detectors for the frames, averaged over all of them, and the factor the
+// frames were scaled down by for faster processing.
+func (o smartcropAnalyzer) features(frames []image.Image) (*image.RGBA, []float64, float64) {
+	prescalefactor := o.prescaleFactor(frames[0])
+
+	var features *image.RGBA
+	var extraMap []float64
+	var sums []int
+	for _, frame := range frames {
+		lowimg := o.downscale(frame, prescalefactor)
+		if o.logger.DebugMode && features == nil {
+			_ = writeImage("png", lowimg, "./smartcrop_prescale.png")
+		}
+
+		l := laplacian(makeCies(lowimg), lowimg.Bounds().Dx(), lowimg.Bounds().Dy())
+		features = detectFeatures(o.logger, lowimg, l)
+		if extra := o.detect(lowimg, l); extraMap == nil {
+			extraMap = extra
+		} else {
+			for i, v := range extra {
+				extraMap[i] += v
+			}
+		}
+		if len(frames) > 1 {
+			if sums == nil {
+				sums = make([]int, len(features.Pix))
+			}
+			for i, v := range features.Pix {
+				sums[i] += int(v)
+			}
+		}
+	}
+	if sums != nil {
+		for i, v := range sums {
+			features.Pix[i] = uint8(v / len(frames))
+		}
+		for i := range extraMap {
+			extraMap[i] /= float64(len(frames))
+		}
+	}
+
+	return features, extraMap, prescalefactor
+}
+
+// detect returns the weighted sum of the importance found by the additional
+// detectors in the prescaled image img with the Laplacian l of its lightness,
+// or nil if there are no detectors.
+func (o smartcropAnalyzer) detect(img *image.RGBA, l []float64) []float64 {
+	if len(o.detectors) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	extraMap := make([]float64, width*height)
+	for _, d := range o.detectors {
+		var importance []float64
+		if ld, ok := d.Detector.(laplacianDetector); ok {
+			importance = ld.detectLaplacian(l, width, height)
+		} else {
+			importance = d.Detect(img)
+		}
+		for i, v := range importance {
+			extraMap[i] += v * d.weight
+		}
+	}
+	o.logger.Log.Println("Time elapsed detectors:", time.Since(now))
+
+	return extraMap
+}
+
+// upscale scales crops found on a feature map back to the original image,
+// whose bounds start at origin.
+func upscale(crops []Crop, prescalefactor float64, origin image.Point) {
+	for i, c := range crops {
+		if prescale == true {
+			c.Min.X = int(chop(float64(c.Min.X) / prescalefactor))
+			c.Min.Y = int(chop(float64(c.Min.Y) / prescalefactor))
+			c.Max.X = int(chop(float64(c.Max.X) / prescalefactor))
+			c.Max.Y = int(chop(float64(c.Max.Y) / prescalefactor))
+		}
+		c.Rectangle = c.Canon().Add(origin)
+		crops[i] = c
+	}
+}
+
+// prescaleFactor returns the factor img gets scaled down by for faster
+// processing.
+func (o smartcropAnalyzer) prescaleFactor(img image.Image) float64 {
 	var prescalefactor = 1.0
 
 	if prescale {
@@ -142,41 +367,25 @@ func (o smartcropAnalyzer) FindBestCrop(img image.Image, width, height int) (ima
 			prescalefactor = f
 		}
 		o.logger.Log.Println(prescalefactor)
-
-		smallimg := o.Resize(
-			img,
-			uint(float64(img.Bounds().Dx())*prescalefactor),
-			0)
-
-		lowimg = toRGBA(smallimg)
-	} else {
-		lowimg = toRGBA(img)
-	}
-
-	if o.logger.DebugMode {
-		_ = writeImage("png", lowimg, "./smartcrop_prescale.png")
 	}
+	return prescalefactor
+}
 
-	cropWidth, cropHeight := chop(float64(width)*scale*prescalefactor), chop(float64(height)*scale*prescalefactor)
-	realMinScale := math.Min(maxScale, math.Max(1.0/scale, minScale))
-
-	o.logger.Log.Printf("original resolution: %dx%d\n", img.Bounds().Dx(), img.Bounds().Dy())
-	o.logger.Log.Printf("scale: %f, cropw: %f, croph: %f, minscale: %f\n", scale, cropWidth, cropHeight, realMinScale)
-
-	topCrop := analyse(o.logger, lowimg, cropWidth, cropHeight, realMinScale)
-
-	if prescale == true {
-		topCrop.Min.X = int(chop(float64(topCrop.Min.X) / prescalefactor))
-		topCrop.Min.Y = int(chop(float64(topCrop.Min.Y) / prescalefactor))
-		topCrop.Max.X = int(chop(float64(topCrop.Max.X) / prescalefactor))
-		topCrop.Max.Y = int(chop(float64(topCrop.Max.Y) / prescalefactor))
+// downscale returns img scaled down by prescalefactor for faster processing.
+func (o smartcropAnalyzer) downscale(img image.Image, prescalefactor float64) *image.RGBA {
+	if !prescale {
+		return toRGBA(img)
 	}
 
-	return topCrop.Canon(), nil
+	smallimg := o.Resize(
+		img,
+		uint(float64(img.Bounds().Dx())*prescalefactor),
+		0)
+	return toRGBA(smallimg)
 }
 
 func (c Crop) totalScore() float64 {
-	return (c.Score.Detail*detailWeight + c.Score.Skin*skinWeight + c.Score.Saturation*saturationWeight) / float64(c.Dx()) / float64(c.Dy())
+	return (c.Score.Detail*detailWeight + c.Score.Skin*skinWeight + c.Score.Saturation*saturationWeight + c.Score.Boost*boostWeight + c.Score.Extra) / float64(c.Dx()) / float64(c.Dy())
 }
 
 func chop(x float64) float64 {
@@ -218,7 +427,7 @@ func importance(crop Crop, x, y int) float64 {
 	return s + d
 }
 
-func score(output *image.RGBA, crop Crop) Score {
+func score(output *image.RGBA, boostMap, extraMap []float64, crop Crop) Score {
 	width := output.Bounds().Dx()
 	height := output.Bounds().Dy()
 	score := Score{}
@@ -239,17 +448,37 @@ func score(output *image.RGBA, crop Crop) Score {
 			score.Skin += r8 / 255.0 * (det + skinBias) * imp
 			score.Detail += det * imp
 			score.Saturation += b8 / 255.0 * (det + saturationBias) * imp
+			if boostMap != nil {
+				if b := boostMap[y*width+x]; b > 0 {
+					score.Boost += b * imp
+				} else if b < 0 && image.Pt(x, y).In(crop.Rectangle) {
+					// importance turns negative towards the edges of a crop,
+					// so regions to avoid get penalized anywhere inside it
+					score.Boost += b
+				}
+			}
+			if extraMap != nil {
+				// negative importance is treated like a region to avoid
+				if e := extraMap[y*width+x]; e > 0 {
+					score.Extra += e * imp
+				} else if e < 0 && image.Pt(x, y).In(crop.Rectangle) {
+					score.Extra += e
+				}
+			}
 		}
 	}
 
 	return score
 }
 
-func analyse(logger Logger, img *image.RGBA, cropWidth, cropHeight, realMinScale float64) image.Rectangle {
+// detectFeatures returns the feature map of img with the Laplacian l of its
+// lightness: the red channel holds the skin tones, the green channel the
+// details and the blue channel the saturation.
+func detectFeatures(logger Logger, img *image.RGBA, l []float64) *image.RGBA {
 	o := image.NewRGBA(img.Bounds())
 
 	now := time.Now()
-	edgeDetect(img, o)
+	edgeDetect(l, o)
 	logger.Log.Println("Time elapsed edge:", time.Since(now))
 	debugOutput(logger.DebugMode, o, "edge")
 
@@ -263,30 +492,90 @@ func analyse(logger Logger, img *image.RGBA, cropWidth, cropHeight, realMinScale
 	logger.Log.Println("Time elapsed sat:", time.Since(now))
 	debugOutput(logger.DebugMode, o, "saturation")
 
-	now = time.Now()
-	var topCrop Crop
-	topScore := -1.0
-	cs := crops(o, cropWidth, cropHeight, realMinScale)
-	logger.Log.Println("Time elapsed crops:", time.Since(now), len(cs))
+	return o
+}
+
+// analyse scores all possible crops on the feature map o and returns the count
+// best of them, or all if count is 0, sorted by their total score, best first.
+func analyse(logger Logger, o *image.RGBA, boostMap, extraMap []float64, cropWidth, cropHeight, realMinScale float64, count int) []Crop {
+	now := time.Now()
+	all := crops(o, cropWidth, cropHeight, realMinScale)
+	logger.Log.Println("Time elapsed crops:", time.Since(now), len(all))
 
 	now = time.Now()
-	for _, crop := range cs {
+	if count <= 0 || count > len(all) {
+		count = len(all)
+	}
+	top := make(cropHeap, 0, count)
+	for i, crop := range all {
 		nowIn := time.Now()
-		crop.Score = score(o, crop)
+		crop.Score = score(o, boostMap, extraMap, crop)
+		crop.Score.Total = crop.totalScore()
 		logger.Log.Println("Time elapsed single-score:", time.Since(nowIn))
-		if crop.totalScore() > topScore {
-			topCrop = crop
-			topScore = crop.totalScore()
+
+		c := rankedCrop{Crop: crop, index: i}
+		switch {
+		case len(top) < cap(top):
+			top = append(top, c)
+			if len(top) == cap(top) {
+				heap.Init(&top)
+			}
+		case top[0].worse(c):
+			top[0] = c
+			heap.Fix(&top, 0)
 		}
 	}
+	// of equally scored crops the first one stays on top
+	sort.Slice(top, func(i, j int) bool {
+		return top[j].worse(top[i])
+	})
+	cs := make([]Crop, len(top))
+	for i, c := range top {
+		cs[i] = c.Crop
+	}
 	logger.Log.Println("Time elapsed score:", time.Since(now))
 
+	if len(cs) == 0 {
+		// no crop fits into the image
+		cs = []Crop{{}}
+	}
+
 	if logger.DebugMode {
-		drawDebugCrop(topCrop, o)
+		drawDebugCrop(cs[0], o)
 		debugOutput(true, o, "final")
 	}
 
-	return topCrop.Rectangle
+	return cs
+}
+
+// rankedCrop is a scored crop along with its position among the candidates,
+// which breaks ties between equal scores.
+type rankedCrop struct {
+	Crop
+	index int
+}
+
+// worse reports whether c ranks below d.
+func (c rankedCrop) worse(d rankedCrop) bool {
+	if c.Score.Total != d.Score.Total {
+		return c.Score.Total < d.Score.Total
+	}
+	return c.index > d.index
+}
+
+// cropHeap is a heap of crops with the worst one on top, used to keep the
+// best crops while scoring the candidates.
+type cropHeap []rankedCrop
+
+func (h cropHeap) Len() int            { return len(h) }
+func (h cropHeap) Less(i, j int) bool  { return h[i].worse(h[j]) }
+func (h cropHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *cropHeap) Push(x interface{}) { *h = append(*h, x.(rankedCrop)) }
+func (h *cropHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
 }
 
 func saturation(c color.RGBA) float64 {
@@ -357,26 +646,13 @@ func makeCies(img *image.RGBA) []float64 {
 	return cies
 }
 
-func edgeDetect(i *image.RGBA, o *image.RGBA) {
-	width := i.Bounds().Dx()
-	height := i.Bounds().Dy()
-	cies := makeCies(i)
+func edgeDetect(l []float64, o *image.RGBA) {
+	width := o.Bounds().Dx()
+	height := o.Bounds().Dy()
 
-	var lightness float64
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			if x == 0 || x >= width-1 || y == 0 || y >= height-1 {
-				//lightness = cie((*i).At(x, y))
-				lightness = 0
-			} else {
-				lightness = cies[y*width+x]*4.0 -
-					cies[x+(y-1)*width] -
-					cies[x-1+y*width] -
-					cies[x+1+y*width] -
-					cies[x+(y+1)*width]
-			}
-
-			nc := color.RGBA{0, uint8(bounds(lightness)), 0, 255}
+			nc := color.RGBA{0, uint8(bounds(l[y*width+x])), 0, 255}
 			o.SetRGBA(x, y, nc)
 		}
 	}
@@ -426,6 +702,36 @@ func saturationDetect(i *image.RGBA, o *image.RGBA) {
 	}
 }
 
+// makeBoostMap returns the boost of every pixel of the prescaled image img, or
+// nil if there are no boosts. The boosts are relative to an image starting at
+// origin, scaled down by prescalefactor. Overlapping boosts add up.
+func makeBoostMap(img *image.RGBA, boosts []Boost, origin image.Point, prescalefactor float64) []float64 {
+	if len(boosts) == 0 {
+		return nil
+	}
+
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	boostMap := make([]float64, width*height)
+	for _, b := range boosts {
+		r := b.Sub(origin)
+		r = image.Rect(
+			int(chop(float64(r.Min.X)*prescalefactor)),
+			int(chop(float64(r.Min.Y)*prescalefactor)),
+			int(math.Ceil(float64(r.Max.X)*prescalefactor)),
+			int(math.Ceil(float64(r.Max.Y)*prescalefactor)),
+		).Intersect(image.Rect(0, 0, width, height))
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				boostMap[y*width+x] += b.Weight
+			}
+		}
+	}
+
+	return boostMap
+}
+
 func crops(i image.Image, cropWidth, cropHeight, realMinScale float64) []Crop {
 	res := []Crop{}
 	width := i.Bounds().Dx()
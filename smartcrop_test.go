@@ -28,17 +28,28 @@
 package smartcrop
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io/ioutil"
+	"math"
+	"math/cmplx"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/muesli/smartcrop/nfnt"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
 )
 
 var (
@@ -83,6 +94,811 @@ func TestCrop(t *testing.T) {
 	}
 }
 
+func TestFindBestCrops(t *testing.T) {
+	fi, _ := os.Open(testFile)
+	defer fi.Close()
+
+	img, _, err := image.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(CropsAnalyzer)
+	crops, err := analyzer.FindBestCrops(img, 250, 250, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crops) != 5 {
+		t.Fatalf("expected 5 crops, got %d", len(crops))
+	}
+	expected := image.Rect(464, 24, 719, 279)
+	if crops[0].Rectangle != expected {
+		t.Fatalf("expected %v, got %v", expected, crops[0].Rectangle)
+	}
+	for i := 1; i < len(crops); i++ {
+		if crops[i].Score.Total > crops[i-1].Score.Total {
+			t.Errorf("crop %d scores higher than crop %d", i, i-1)
+		}
+	}
+}
+
+func TestBoost(t *testing.T) {
+	fi, _ := os.Open(testFile)
+	defer fi.Close()
+
+	img, _, err := image.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boost := Boost{Rectangle: image.Rect(20, 100, 120, 200), Weight: 1}
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(BoostAnalyzer).WithBoosts([]Boost{boost})
+	topCrop, err := analyzer.FindBestCrop(img, 250, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !boost.In(topCrop) {
+		t.Fatalf("expected %v to contain the boosted region %v", topCrop, boost.Rectangle)
+	}
+
+	avoid := Boost{Rectangle: image.Rect(500, 50, 700, 250), Weight: -1}
+	analyzer = NewAnalyzer(nfnt.NewDefaultResizer()).(BoostAnalyzer).WithBoosts([]Boost{avoid})
+	topCrop, err = analyzer.FindBestCrop(img, 250, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topCrop.Overlaps(avoid.Rectangle) {
+		t.Fatalf("expected %v not to overlap the avoided region %v", topCrop, avoid.Rectangle)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	fi, _ := os.Open(testFile)
+	defer fi.Close()
+
+	img, _, err := image.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errOpen := errors.New("can't open image")
+	jobs := make(chan Job)
+	go func() {
+		for i := 0; i < 4; i++ {
+			jobs <- Job{ID: i, Image: img, Width: 250, Height: 250}
+		}
+		jobs <- Job{ID: "broken", Open: func() (image.Image, error) { return nil, errOpen }, Width: 250, Height: 250}
+		jobs <- Job{ID: "empty", Width: 250, Height: 250}
+		close(jobs)
+	}()
+
+	var done, failed int
+	b := Batch{
+		Analyzer: NewAnalyzer(nfnt.NewDefaultResizer()),
+		Workers:  2,
+		Progress: func(r Result, d, f int) {
+			done, failed = d, f
+		},
+	}
+
+	expected := image.Rect(464, 24, 719, 279)
+	n := 0
+	for r := range b.Run(context.Background(), jobs) {
+		n++
+		if r.Job.ID == "broken" {
+			if r.Err != errOpen {
+				t.Errorf("expected %v, got %v", errOpen, r.Err)
+			}
+			continue
+		}
+		if r.Job.ID == "empty" {
+			if r.Err != ErrNoImage {
+				t.Errorf("expected %v, got %v", ErrNoImage, r.Err)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Crop.Rectangle != expected {
+			t.Errorf("job %v: expected %v, got %v", r.Job.ID, expected, r.Crop.Rectangle)
+		}
+	}
+	if n != 6 || done != 6 || failed != 2 {
+		t.Fatalf("expected 6 results with 2 failures, got %d results, %d done, %d failed", n, done, failed)
+	}
+
+	if r := (&Batch{}).process(Job{Image: img, Width: 250, Height: 250}); r.Err != ErrNoAnalyzer {
+		t.Fatalf("expected %v, got %v", ErrNoAnalyzer, r.Err)
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs := make(chan Job)
+	results := (&Batch{Analyzer: NewAnalyzer(nfnt.NewDefaultResizer())}).Run(ctx, jobs)
+
+	// without any jobs, the results channel only gets closed on cancellation
+	cancel()
+	if _, ok := <-results; ok {
+		t.Fatal("expected no results")
+	}
+
+	// a result dropped on cancellation doesn't count as done
+	ctx, cancel = context.WithCancel(context.Background())
+	opened := make(chan struct{})
+	jobs = make(chan Job, 1)
+	jobs <- Job{Open: func() (image.Image, error) {
+		close(opened)
+		return nil, errors.New("can't open image")
+	}}
+	done := 0
+	b := Batch{
+		Analyzer: NewAnalyzer(nfnt.NewDefaultResizer()),
+		Workers:  1,
+		Progress: func(r Result, d, f int) {
+			done = d
+		},
+	}
+	results = b.Run(ctx, jobs)
+	<-opened
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	n := 0
+	for range results {
+		n++
+	}
+	if done != n {
+		t.Fatalf("expected progress to count %d delivered results, got %d", n, done)
+	}
+}
+
+func TestAnimation(t *testing.T) {
+	fi, _ := os.Open(testFile)
+	defer fi.Close()
+
+	img, _, err := image.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a still animation gets the same crop as its frame
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(AnimationAnalyzer)
+	crops, err := analyzer.FindBestAnimationCrops([]image.Image{img, img, img}, 250, 250, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := image.Rect(464, 24, 719, 279)
+	if crops[0].Rectangle != expected {
+		t.Fatalf("expected %v, got %v", expected, crops[0].Rectangle)
+	}
+
+	if _, err := analyzer.FindBestAnimationCrops(nil, 250, 250, 1); err != ErrNoFrames {
+		t.Fatalf("expected %v, got %v", ErrNoFrames, err)
+	}
+}
+
+func TestGIF(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.White, color.RGBA{255, 0, 0, 255}}
+	g := &gif.GIF{
+		Config:    image.Config{ColorModel: palette, Width: 200, Height: 100},
+		LoopCount: 3,
+	}
+	background := image.NewPaletted(image.Rect(0, 0, 200, 100), palette)
+	draw.Draw(background, background.Bounds(), image.White, image.Point{}, draw.Src)
+	g.Image = append(g.Image, background)
+	g.Delay = append(g.Delay, 10)
+	g.Disposal = append(g.Disposal, gif.DisposalNone)
+	// a red square moving from left to right, drawn as partial frames
+	for x := 0; x < 150; x += 50 {
+		f := image.NewPaletted(image.Rect(x, 25, x+50, 75), palette)
+		draw.Draw(f, f.Bounds(), image.NewUniform(palette[2]), image.Point{}, draw.Src)
+		g.Image = append(g.Image, f)
+		g.Delay = append(g.Delay, 20)
+		g.Disposal = append(g.Disposal, gif.DisposalPrevious)
+	}
+
+	frames := GIFFrames(g, 0)
+	if len(frames) != 4 {
+		t.Fatalf("expected 4 frames, got %d", len(frames))
+	}
+	// the square of the previous frame must be gone
+	if c := color.RGBAModel.Convert(frames[2].At(10, 50)).(color.RGBA); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Fatalf("expected the disposed square to be white, got %v", c)
+	}
+	if n := len(GIFFrames(g, 2)); n != 2 {
+		t.Fatalf("expected 2 sampled frames, got %d", n)
+	}
+
+	r, err := FindBestGIFCrop(NewAnalyzer(nfnt.NewDefaultResizer()), g, 100, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Dx() != r.Dy() || !r.In(image.Rect(0, 0, 200, 100)) {
+		t.Fatalf("expected a square crop within the image, got %v", r)
+	}
+
+	out := CropGIF(g, r, 50, 50, nfnt.NewDefaultResizer())
+	if len(out.Image) != len(g.Image) || out.LoopCount != g.LoopCount {
+		t.Fatalf("expected %d frames looping %d times, got %d frames looping %d times",
+			len(g.Image), g.LoopCount, len(out.Image), out.LoopCount)
+	}
+	for i, f := range out.Image {
+		if f.Bounds() != image.Rect(0, 0, 50, 50) {
+			t.Errorf("frame %d: expected 50x50, got %v", i, f.Bounds())
+		}
+		if out.Delay[i] != g.Delay[i] || out.Disposal[i] != gif.DisposalNone {
+			t.Errorf("frame %d: expected delay %d and no disposal, got %d and %d",
+				i, g.Delay[i], out.Delay[i], out.Disposal[i])
+		}
+	}
+	if err := gif.EncodeAll(ioutil.Discard, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCropGIFDisposal(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	square := func(r image.Rectangle, c uint8) *image.Paletted {
+		f := image.NewPaletted(r, palette)
+		for i := range f.Pix {
+			f.Pix[i] = c
+		}
+		return f
+	}
+	// a red square stays, blue squares flash up next to it and get restored
+	// away, partly over a transparent background
+	g := &gif.GIF{
+		Config: image.Config{ColorModel: palette, Width: 100, Height: 100},
+		Image: []*image.Paletted{
+			square(image.Rect(0, 0, 100, 100), 0),
+			square(image.Rect(0, 0, 40, 40), 1),
+			square(image.Rect(50, 0, 100, 50), 2),
+			square(image.Rect(0, 50, 50, 100), 2),
+			square(image.Rect(90, 90, 100, 100), 0),
+		},
+		Delay:    []int{10, 10, 10, 10, 10},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone, gif.DisposalPrevious, gif.DisposalPrevious, gif.DisposalBackground},
+	}
+
+	out := CropGIF(g, image.Rect(0, 0, 100, 100), 100, 100, nil)
+	// every frame is followed by one with transparent pixels
+	for i, d := range out.Disposal {
+		if d != gif.DisposalBackground {
+			t.Errorf("frame %d: expected disposal %d, got %d", i, gif.DisposalBackground, d)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, got := GIFFrames(g, 0), GIFFrames(decoded, 0)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d frames, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 100; x++ {
+				e := color.RGBAModel.Convert(expected[i].At(x, y))
+				if c := color.RGBAModel.Convert(got[i].At(x, y)); c != e {
+					t.Fatalf("frame %d: expected %v at %d,%d, got %v", i, e, x, y, c)
+				}
+			}
+		}
+	}
+}
+
+func TestCropGIFPalettes(t *testing.T) {
+	white := color.Palette{color.White}
+	red := color.Palette{color.RGBA{255, 0, 0, 255}}
+	g := &gif.GIF{
+		Config:   image.Config{Width: 100, Height: 100},
+		Delay:    []int{10, 10},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+	}
+	background := image.NewPaletted(image.Rect(0, 0, 100, 100), white)
+	// the second frame only covers the right half and has no white in its palette
+	square := image.NewPaletted(image.Rect(50, 0, 100, 100), red)
+	g.Image = []*image.Paletted{background, square}
+
+	out := CropGIF(g, image.Rect(0, 0, 100, 100), 100, 100, nil)
+	f := out.Image[1]
+	if c := color.RGBAModel.Convert(f.At(10, 50)).(color.RGBA); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the left half to stay white, got %v", c)
+	}
+	if c := color.RGBAModel.Convert(f.At(90, 50)).(color.RGBA); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the right half to be red, got %v", c)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	fi, _ := os.Open(testFile)
+	defer fi.Close()
+
+	img, _, err := image.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirrored := image.NewRGBA(img.Bounds())
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			mirrored.Set(b.Max.X-1-(x-b.Min.X), y, img.At(x, y))
+		}
+	}
+
+	s := NewSequenceAnalyzer(nfnt.NewDefaultResizer(), 1, 1, SequenceOptions{MaxPan: 0.01, SceneCut: 2})
+	first, err := s.Next(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.SceneCut {
+		t.Fatal("expected the first frame to start a scene")
+	}
+	if first.Dx() != 284 || first.Dy() != 284 {
+		t.Fatalf("expected a 284x284 window, got %v", first.Rectangle)
+	}
+
+	// the content jumps, but the window pans with limited speed
+	prev := first
+	for i := 0; i < 60; i++ {
+		c, err := s.Next(mirrored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.SceneCut {
+			t.Fatalf("frame %d: unexpected scene cut", i)
+		}
+		if d := c.Min.X - prev.Min.X; d < -10 || d > 10 {
+			t.Fatalf("frame %d: expected to move at most 10 pixels, moved %d", i, d)
+		}
+		prev = c
+	}
+	if prev == first {
+		t.Fatal("expected the window to follow the content")
+	}
+
+	// with scene cut detection, the window jumps
+	s = NewSequenceAnalyzer(nfnt.NewDefaultResizer(), 1, 1, SequenceOptions{})
+	if _, err := s.Next(img); err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.Next(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SceneCut || c.Rectangle != first.Rectangle {
+		t.Fatalf("expected the same scene to keep the window at %v, got %v", first.Rectangle, c)
+	}
+	c, err = s.Next(mirrored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.SceneCut {
+		t.Fatal("expected a scene cut")
+	}
+}
+
+func TestKenBurns(t *testing.T) {
+	fi, _ := os.Open(testFile)
+	defer fi.Close()
+
+	img, _, err := image.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(KenBurnsAnalyzer)
+	k, err := analyzer.FindKenBurns(img, 160, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Start == k.End {
+		t.Fatalf("expected the path to move, got %v", k.Start)
+	}
+	for _, r := range []image.Rectangle{k.Start, k.End} {
+		if !r.In(img.Bounds()) {
+			t.Errorf("expected %v to be within %v", r, img.Bounds())
+		}
+		if ratio := float64(r.Dx()) / float64(r.Dy()); math.Abs(ratio-16.0/9.0) > 0.05 {
+			t.Errorf("expected %v to have an aspect ratio of 16:9, got %f", r, ratio)
+		}
+	}
+
+	if r := k.Frame(0); r != k.Start {
+		t.Errorf("expected the path to start at %v, got %v", k.Start, r)
+	}
+	if r := k.Frame(1); r != k.End {
+		t.Errorf("expected the path to end at %v, got %v", k.End, r)
+	}
+	if e := EaseInOut(0.5); e != 0.5 {
+		t.Errorf("expected EaseInOut(0.5) to be 0.5, got %f", e)
+	}
+}
+
+func TestFindBestFrames(t *testing.T) {
+	fi, _ := os.Open(testFile)
+	defer fi.Close()
+
+	img, _, err := image.Decode(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a blurred and a darkened copy must lose against the original
+	resizer := nfnt.NewDefaultResizer()
+	b := img.Bounds()
+	blurred := resizer.Resize(resizer.Resize(img, uint(b.Dx()/8), 0), uint(b.Dx()), uint(b.Dy()))
+	dark := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			dark.SetRGBA(x, y, color.RGBA{c.R / 6, c.G / 6, c.B / 6, 255})
+		}
+	}
+
+	analyzer := NewAnalyzer(resizer).(FrameAnalyzer)
+	frames, err := analyzer.FindBestFrames([]image.Image{blurred, img, dark}, 250, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(frames))
+	}
+	if frames[0].Frame != 1 {
+		t.Fatalf("expected frame 1 to be the best, got %d", frames[0].Frame)
+	}
+	expected := image.Rect(464, 24, 719, 279)
+	if frames[0].Rectangle != expected {
+		t.Fatalf("expected %v, got %v", expected, frames[0].Rectangle)
+	}
+	for _, f := range frames {
+		if f.Value < 0 || f.Value > 1 || f.Quality < 0 || f.Quality > 1 {
+			t.Errorf("frame %d: expected value and quality between 0 and 1, got %f and %f", f.Frame, f.Value, f.Quality)
+		}
+		if f.Frame != 1 && f.Quality >= frames[0].Quality {
+			t.Errorf("frame %d: expected a lower quality than %f, got %f", f.Frame, frames[0].Quality, f.Quality)
+		}
+	}
+}
+
+// stripesWithSquare returns an image of regular stripes with a uniform square
+// at r, which stands out although it has no detail at all.
+func stripesWithSquare(r image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{40, 40, 40, 255}
+			if (x/4)%2 == 0 {
+				c = color.RGBA{200, 200, 200, 255}
+			}
+			if image.Pt(x, y).In(r) {
+				c = color.RGBA{120, 120, 120, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestFFT(t *testing.T) {
+	values := make([]complex128, 16)
+	for i := range values {
+		values[i] = complex(float64(i%5), float64(i%3))
+	}
+	orig := append([]complex128(nil), values...)
+
+	fft2(values, 4, false)
+	fft2(values, 4, true)
+	for i := range values {
+		if cmplx.Abs(values[i]-orig[i]) > 1e-9 {
+			t.Fatalf("value %d: expected %v after the round trip, got %v", i, orig[i], values[i])
+		}
+	}
+}
+
+func TestSpectralResidual(t *testing.T) {
+	square := image.Rect(300, 80, 340, 120)
+	img := stripesWithSquare(square)
+
+	saliency := SpectralResidual{}.Detect(img)
+	if len(saliency) != 400*200 {
+		t.Fatalf("expected %d values, got %d", 400*200, len(saliency))
+	}
+	var inside, outside float64
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			if image.Pt(x, y).In(square) {
+				inside += saliency[y*400+x] / float64(square.Dx()*square.Dy())
+			} else {
+				outside += saliency[y*400+x] / float64(400*200-square.Dx()*square.Dy())
+			}
+		}
+	}
+	if inside <= 2*outside {
+		t.Fatalf("expected the square to be salient, got %f inside and %f outside", inside, outside)
+	}
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(DetectorAnalyzer).WithDetector(SpectralResidual{}, 5)
+	topCrop, err := analyzer.FindBestCrop(img, 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !square.In(topCrop) {
+		t.Fatalf("expected %v to contain the salient square %v", topCrop, square)
+	}
+}
+
+func TestColorContrast(t *testing.T) {
+	// a grey square on a vivid background
+	square := image.Rect(40, 120, 90, 170)
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{230, uint8(40 + x/4), 30, 255}
+			if image.Pt(x, y).In(square) {
+				c = color.RGBA{128, 128, 128, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	contrast := ColorContrast{}.Detect(img)
+	if len(contrast) != 400*200 {
+		t.Fatalf("expected %d values, got %d", 400*200, len(contrast))
+	}
+	if in, out := contrast[145*400+65], contrast[50*400+300]; in <= out {
+		t.Fatalf("expected the grey square to stand out, got %f inside and %f outside", in, out)
+	}
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(DetectorAnalyzer).WithDetector(ColorContrast{}, 5)
+	topCrop, err := analyzer.FindBestCrop(img, 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !square.In(topCrop) {
+		t.Fatalf("expected %v to contain the grey square %v", topCrop, square)
+	}
+}
+
+// plainWithSquare returns a plain grey image with a colourful square at r.
+func plainWithSquare(r image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{128, 128, 128, 255}
+			if image.Pt(x, y).In(r) {
+				c = color.RGBA{uint8(x * 7), uint8(y * 13), 200, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestBestCropsOrder(t *testing.T) {
+	img := plainWithSquare(image.Rect(300, 50, 360, 110))
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(CropsAnalyzer)
+
+	all, err := analyzer.FindBestCrops(img, 100, 100, math.MaxInt32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Score.Total > all[i-1].Score.Total {
+			t.Fatalf("expected crop %d to score at most %f, got %f", i, all[i-1].Score.Total, all[i].Score.Total)
+		}
+	}
+
+	// keeping only the best crops must yield the same ones, in the same order
+	for _, count := range []int{1, 5, 50} {
+		crops, err := analyzer.FindBestCrops(img, 100, 100, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(crops) != count {
+			t.Fatalf("expected %d crops, got %d", count, len(crops))
+		}
+		for i, c := range crops {
+			if c != all[i] {
+				t.Fatalf("count %d: expected %v at %d, got %v", count, all[i], i, c)
+			}
+		}
+	}
+}
+
+func TestSubImageCrop(t *testing.T) {
+	square := image.Rect(300, 50, 360, 110)
+	sub := plainWithSquare(square).SubImage(image.Rect(200, 0, 400, 200))
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(CropsAnalyzer)
+	crops, err := analyzer.FindBestCrops(sub, 100, 100, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range crops {
+		if !c.In(sub.Bounds()) {
+			t.Fatalf("expected %v within the bounds of the sub-image %v", c.Rectangle, sub.Bounds())
+		}
+	}
+	if !square.In(crops[0].Rectangle) {
+		t.Fatalf("expected %v to contain the square %v", crops[0].Rectangle, square)
+	}
+}
+
+func TestDebugImageSubImage(t *testing.T) {
+	sub := plainWithSquare(image.Rect(300, 50, 360, 110)).SubImage(image.Rect(200, 0, 400, 200))
+	crops, err := NewAnalyzer(nfnt.NewDefaultResizer()).(CropsAnalyzer).FindBestCrops(sub, 100, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	debug := DebugImage(sub, crops[0], nil)
+	if debug.Bounds() != image.Rect(0, 0, 200, 200) {
+		t.Fatalf("expected the debug image to start at (0, 0), got %v", debug.Bounds())
+	}
+	// the top left corner of the crop gets outlined in cyan
+	corner := crops[0].Min.Sub(sub.Bounds().Min)
+	if c := debug.RGBAAt(corner.X, corner.Y); c != (color.RGBA{0, 255, 255, 255}) {
+		t.Fatalf("expected the outline of %v at %v, got %v", crops[0].Rectangle, corner, c)
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	square := image.Rect(300, 50, 360, 110)
+	img := plainWithSquare(square)
+
+	expected := map[string]image.Rectangle{
+		"low":    image.Rect(0, 0, 200, 200),
+		"centre": image.Rect(100, 0, 300, 200),
+		"high":   image.Rect(200, 0, 400, 200),
+	}
+	for name, r := range expected {
+		analyzer, err := NewStrategyAnalyzer(name, nfnt.NewDefaultResizer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		topCrop, err := analyzer.FindBestCrop(img, 100, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if topCrop != r {
+			t.Fatalf("expected %v for strategy %s, got %v", r, name, topCrop)
+		}
+	}
+
+	for _, name := range []string{"smart", "entropy", "attention"} {
+		analyzer, err := NewStrategyAnalyzer(name, nfnt.NewDefaultResizer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		topCrop, err := analyzer.FindBestCrop(img, 100, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !square.In(topCrop) {
+			t.Fatalf("expected %v for strategy %s to contain the square %v", topCrop, name, square)
+		}
+	}
+
+	if _, err := NewStrategyAnalyzer("none", nfnt.NewDefaultResizer()); err != ErrUnknownStrategy {
+		t.Fatalf("expected ErrUnknownStrategy, got %v", err)
+	}
+}
+
+func TestFindFocalPoint(t *testing.T) {
+	square := image.Rect(300, 50, 360, 110)
+	img := plainWithSquare(square)
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(FocalPointAnalyzer)
+	fp, err := analyzer.FindFocalPoint(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fp.Point.In(square) {
+		t.Fatalf("expected the focal point %v within the square %v", fp.Point, square)
+	}
+	if fp.X != float64(fp.Point.X)/400 || fp.Y != float64(fp.Point.Y)/200 {
+		t.Fatalf("expected the normalized focal point to match %v, got %f, %f", fp.Point, fp.X, fp.Y)
+	}
+	if !fp.Point.In(fp.Safe) || !fp.Safe.Overlaps(square) || fp.Safe.Dx() > 100 || fp.Safe.Dy() > 100 {
+		t.Fatalf("expected a safe rectangle around the square %v, got %v", square, fp.Safe)
+	}
+
+	fp, err = analyzer.FindFocalPoint(image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp.Point != image.Pt(50, 25) || !fp.Safe.Empty() {
+		t.Fatalf("expected the centre and no safe rectangle for a blank image, got %v", fp)
+	}
+}
+
+func TestFocusDetector(t *testing.T) {
+	// a finely textured square in front of large, soft blobs
+	subject := image.Rect(30, 130, 80, 180)
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			v := 0.5 + 0.5*math.Sin(float64(x)/4)*math.Sin(float64(y)/4)
+			c := color.RGBA{uint8(60 + 190*v), uint8(40 + 150*v), uint8(200 - 150*v), 255}
+			if image.Pt(x, y).In(subject) {
+				c = color.RGBA{124, 124, 124, 255}
+				if (x/2+y/2)%2 == 0 {
+					c = color.RGBA{134, 134, 134, 255}
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	focus := FocusDetector{}.Detect(img)
+	if len(focus) != 400*200 {
+		t.Fatalf("expected %d values, got %d", 400*200, len(focus))
+	}
+	if in, out := focus[155*400+55], focus[50*400+300]; in <= 0 || out >= 0 {
+		t.Fatalf("expected the square to be in focus and the background not, got %f inside and %f outside", in, out)
+	}
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(DetectorAnalyzer).WithDetector(FocusDetector{}, 2)
+	topCrop, err := analyzer.FindBestCrop(img, 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !subject.In(topCrop) {
+		t.Fatalf("expected %v to contain the square in focus %v", topCrop, subject)
+	}
+}
+
+func TestTextDetector(t *testing.T) {
+	// two lines of caption at the top left, a colourful square below
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{200, 220, 240, 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(250, 150, 350, 250), image.NewUniform(color.RGBA{220, 120, 60, 255}), image.Point{}, draw.Src)
+	d := font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	for i, line := range []string{"HELLO WORLD CAPTION", "second line of text"} {
+		d.Dot = fixed.P(10, 40+i*16)
+		d.DrawString(line)
+	}
+	caption := image.Rect(10, 30, 143, 59)
+
+	blocks := TextDetector{}.Blocks(img)
+	if len(blocks) != 1 {
+		t.Fatalf("expected a single text block, got %v", blocks)
+	}
+	if !caption.In(blocks[0].Inset(-2)) {
+		t.Fatalf("expected %v to contain the caption %v", blocks[0], caption)
+	}
+
+	analyzer := NewAnalyzer(nfnt.NewDefaultResizer()).(TextAnalyzer)
+	for _, mode := range []TextMode{TextKeep, TextExclude} {
+		crops, err := analyzer.WithTextMode(mode).(CropsAnalyzer).FindBestCrops(img, 100, 100, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range crops {
+			if c.Overlaps(blocks[0]) && !blocks[0].In(c.Rectangle) {
+				t.Fatalf("expected %v not to split the text block %v in mode %d", c.Rectangle, blocks[0], mode)
+			}
+		}
+
+		k, err := analyzer.WithTextMode(mode).(KenBurnsAnalyzer).FindKenBurns(img, 100, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range []image.Rectangle{k.Start, k.End} {
+			if r.Overlaps(blocks[0]) && (mode == TextExclude || !blocks[0].In(r)) {
+				t.Fatalf("expected the pan-and-zoom %v to keep the text block %v whole or out in mode %d", r, blocks[0], mode)
+			}
+		}
+	}
+}
+
 func BenchmarkCrop(b *testing.B) {
 	fi, err := os.Open(testFile)
 	if err != nil {
@@ -119,7 +935,7 @@ func BenchmarkEdge(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		o := image.NewRGBA(img.Bounds())
-		edgeDetect(rgbaImg, o)
+		edgeDetect(laplacian(makeCies(rgbaImg), img.Bounds().Dx(), img.Bounds().Dy()), o)
 	}
 }
 
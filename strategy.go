@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/muesli/smartcrop/options"
+)
+
+const (
+	// number of slices the surplus of an image gets removed in by the
+	// entropy strategy
+	entropySlices = 8
+	// size of the cells the attention strategy sums up the features in
+	attentionCell = 8
+	// standard deviation of the blur applied to the attention map, in cells
+	attentionBlur = 1.5
+)
+
+// ErrUnknownStrategy gets returned when no strategy is registered under the
+// requested name
+var ErrUnknownStrategy = errors.New("unknown strategy")
+
+// Strategy creates an analyzer implementing a cropping strategy.
+type Strategy func(resizer options.Resizer) Analyzer
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]Strategy{
+		"smart":     NewAnalyzer,
+		"entropy":   NewEntropyAnalyzer,
+		"attention": NewAttentionAnalyzer,
+		"centre":    positionStrategy(0.5),
+		"center":    positionStrategy(0.5),
+		"low":       positionStrategy(0),
+		"high":      positionStrategy(1),
+	}
+)
+
+// RegisterStrategy makes a strategy available under name, replacing any
+// strategy previously registered under the same name. Besides the default
+// "smart", the strategies of libvips are registered: "entropy", "attention",
+// "centre" (or "center"), "low" and "high".
+func RegisterStrategy(name string, s Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = s
+}
+
+// Strategies returns the names of the registered strategies, sorted
+// alphabetically.
+func Strategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStrategyAnalyzer returns an analyzer implementing the strategy registered
+// under name.
+func NewStrategyAnalyzer(name string, resizer options.Resizer) (Analyzer, error) {
+	strategiesMu.RLock()
+	s, ok := strategies[name]
+	strategiesMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownStrategy
+	}
+	return s(resizer), nil
+}
+
+// positionStrategy returns a strategy ignoring the content of images: the
+// crop gets placed at position along the axis it has room to move on, from 0
+// (left or top) to 1 (right or bottom).
+func positionStrategy(position float64) Strategy {
+	return func(options.Resizer) Analyzer {
+		return positionAnalyzer{position: position}
+	}
+}
+
+type positionAnalyzer struct {
+	position float64
+}
+
+func (o positionAnalyzer) FindBestCrop(img image.Image, width, height int) (image.Rectangle, error) {
+	cw, ch, err := cropSize(img, width, height)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	b := img.Bounds()
+	x := int(math.Round(float64(b.Dx()-cw) * o.position))
+	y := int(math.Round(float64(b.Dy()-ch) * o.position))
+	return image.Rect(x, y, x+cw, y+ch).Add(b.Min), nil
+}
+
+type entropyAnalyzer struct {
+	options.Resizer
+}
+
+// NewEntropyAnalyzer returns an analyzer behaving like the entropy strategy of
+// libvips: the surplus of the image gets cut off in slices, always removing
+// the one with less entropy of the two opposite slices.
+func NewEntropyAnalyzer(resizer options.Resizer) Analyzer {
+	return entropyAnalyzer{Resizer: resizer}
+}
+
+func (o entropyAnalyzer) FindBestCrop(img image.Image, width, height int) (image.Rectangle, error) {
+	cw, ch, err := cropSize(img, width, height)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	small, f := shrinkImage(o.Resizer, img)
+	sw, sh := small.Bounds().Dx(), small.Bounds().Dy()
+	cies := makeCies(small)
+
+	// entropy of the lightness histogram of the w x h region at x, y
+	entropy := func(x, y, w, h int) float64 {
+		var hist [256]int
+		for yy := y; yy < y+h; yy++ {
+			for xx := x; xx < x+w; xx++ {
+				hist[clamp(int(cies[yy*sw+xx]), 0, 255)]++
+			}
+		}
+		var e float64
+		n := float64(w * h)
+		for _, c := range hist {
+			if c > 0 {
+				p := float64(c) / n
+				e -= p * math.Log2(p)
+			}
+		}
+		return e
+	}
+
+	r := image.Rect(0, 0, sw, sh)
+	tw := clamp(int(math.Round(float64(cw)*f)), 1, sw)
+	th := clamp(int(math.Round(float64(ch)*f)), 1, sh)
+	maxSlice := int(math.Max(1, math.Ceil(float64(sw-tw)/entropySlices)))
+	for r.Dx() > tw {
+		s := clamp(r.Dx()-tw, 1, maxSlice)
+		if entropy(r.Min.X, r.Min.Y, s, r.Dy()) < entropy(r.Max.X-s, r.Min.Y, s, r.Dy()) {
+			r.Min.X += s
+		} else {
+			r.Max.X -= s
+		}
+	}
+	maxSlice = int(math.Max(1, math.Ceil(float64(sh-th)/entropySlices)))
+	for r.Dy() > th {
+		s := clamp(r.Dy()-th, 1, maxSlice)
+		if entropy(r.Min.X, r.Min.Y, r.Dx(), s) < entropy(r.Min.X, r.Max.Y-s, r.Dx(), s) {
+			r.Min.Y += s
+		} else {
+			r.Max.Y -= s
+		}
+	}
+
+	return placeCrop(img.Bounds(), cw, ch, float64(r.Min.X)/f, float64(r.Min.Y)/f), nil
+}
+
+type attentionAnalyzer struct {
+	options.Resizer
+}
+
+// NewAttentionAnalyzer returns an analyzer behaving like the attention
+// strategy of libvips: the crop gets centred on the region with the most
+// skin tones, details and saturation.
+func NewAttentionAnalyzer(resizer options.Resizer) Analyzer {
+	return attentionAnalyzer{Resizer: resizer}
+}
+
+func (o attentionAnalyzer) FindBestCrop(img image.Image, width, height int) (image.Rectangle, error) {
+	cw, ch, err := cropSize(img, width, height)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	small, f := shrinkImage(o.Resizer, img)
+	features := image.NewRGBA(small.Bounds())
+	edgeDetect(laplacian(makeCies(small), small.Bounds().Dx(), small.Bounds().Dy()), features)
+	skinDetect(small, features)
+	saturationDetect(small, features)
+
+	sw, sh := small.Bounds().Dx(), small.Bounds().Dy()
+	attention := make([]float64, sw*sh)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			c := features.RGBAAt(x, y)
+			attention[y*sw+x] = float64(c.R) + float64(c.G) + float64(c.B)
+		}
+	}
+	gw := int(math.Max(1, float64(sw/attentionCell)))
+	gh := int(math.Max(1, float64(sh/attentionCell)))
+	grid := gaussianBlur(shrink(attention, sw, sh, gw, gh), gw, gh, attentionBlur)
+
+	best := 0
+	for i, v := range grid {
+		if v > grid[best] {
+			best = i
+		}
+	}
+	// centre of the best cell in the original image
+	cx := (float64(best%gw) + 0.5) * float64(sw) / float64(gw) / f
+	cy := (float64(best/gw) + 0.5) * float64(sh) / float64(gh) / f
+
+	return placeCrop(img.Bounds(), cw, ch, cx-float64(cw)/2, cy-float64(ch)/2), nil
+}
+
+// cropSize returns the size of the largest crop of img with the aspect ratio
+// of width and height. If one of them is zero, the whole image is the crop.
+func cropSize(img image.Image, width, height int) (int, int, error) {
+	if width == 0 && height == 0 {
+		return 0, 0, ErrInvalidDimensions
+	}
+	b := img.Bounds()
+	if width == 0 || height == 0 {
+		return b.Dx(), b.Dy(), nil
+	}
+	if b.Dx()*height > b.Dy()*width {
+		return b.Dy() * width / height, b.Dy(), nil
+	}
+	return b.Dx(), b.Dx() * height / width, nil
+}
+
+// placeCrop returns the cw x ch crop at x, y relative to the bounds b, moved
+// inside of them.
+func placeCrop(b image.Rectangle, cw, ch int, x, y float64) image.Rectangle {
+	ix := clamp(int(math.Round(x)), 0, b.Dx()-cw)
+	iy := clamp(int(math.Round(y)), 0, b.Dy()-ch)
+	return image.Rect(ix, iy, ix+cw, iy+ch).Add(b.Min)
+}
+
+// shrinkImage returns img downscaled like the smartcrop analyzer does, along
+// with the factor it got scaled by.
+func shrinkImage(resizer options.Resizer, img image.Image) (*image.RGBA, float64) {
+	o := NewAnalyzer(resizer).(*smartcropAnalyzer)
+	f := o.prescaleFactor(img)
+	small := o.downscale(img, f)
+	// the resizer rounds, so use the actual factor
+	return small, float64(small.Bounds().Dx()) / float64(img.Bounds().Dx())
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package smartcrop
+
+import (
+	"image"
+)
+
+const (
+	// minimum lightness difference of the neighbours of a stroke pixel
+	textEdgeThreshold = 48.0
+	// radius of the neighbourhood the density of strokes is measured in
+	textRadius = 3
+	// minimum share of stroke pixels in the neighbourhood of text
+	textDensity = 0.2
+	// minimum share of text pixels within the bounds of a text block
+	textFill = 0.4
+	// minimum height of a text block in pixels of the prescaled image
+	textMinHeight = 4
+	// weight of the boost text blocks get in TextKeep and TextExclude mode
+	textWeight = 1.0
+)
+
+// TextMode controls how crops deal with blocks of text.
+type TextMode int
+
+const (
+	// TextIgnore treats text like any other detail.
+	TextIgnore TextMode = iota
+	// TextKeep prefers crops containing text blocks and never splits them.
+	TextKeep
+	// TextExclude prefers crops without text blocks and never splits them.
+	TextExclude
+)
+
+// TextAnalyzer is implemented by analyzers which can avoid cutting through
+// text, like captions and labels
+type TextAnalyzer interface {
+	Analyzer
+	WithTextMode(mode TextMode) Analyzer
+}
+
+// WithTextMode returns a copy of the analyzer which handles text blocks
+// according to mode. Crops which would split a text block are only chosen if
+// no other crop fits into the image.
+func (o smartcropAnalyzer) WithTextMode(mode TextMode) Analyzer {
+	o.textMode = mode
+	return &o
+}
+
+// textBoosts returns the text blocks of img as boosts according to the text
+// mode, along with the blocks in the prescaled image.
+func (o smartcropAnalyzer) textBoosts(img image.Image, prescalefactor float64) ([]Boost, []image.Rectangle) {
+	if o.textMode == TextIgnore {
+		return nil, nil
+	}
+
+	blocks := TextDetector{}.Blocks(o.downscale(img, prescalefactor))
+	weight := textWeight
+	if o.textMode == TextExclude {
+		weight = -textWeight
+	}
+
+	var boosts []Boost
+	for _, b := range blocks {
+		boosts = append(boosts, Boost{
+			Rectangle: image.Rect(
+				int(chop(float64(b.Min.X)/prescalefactor)),
+				int(chop(float64(b.Min.Y)/prescalefactor)),
+				int(chop(float64(b.Max.X)/prescalefactor)),
+				int(chop(float64(b.Max.Y)/prescalefactor)),
+			).Add(img.Bounds().Min),
+			Weight: weight,
+		})
+	}
+	return boosts, blocks
+}
+
+// unsplit returns the crops which don't cut through any of the blocks,
+// keeping their order. If all crops split a block, they are returned as is.
+func unsplit(crops []Crop, blocks []image.Rectangle) []Crop {
+	if len(blocks) == 0 {
+		return crops
+	}
+
+	var res []Crop
+	for _, c := range crops {
+		split := false
+		for _, b := range blocks {
+			if c.Overlaps(b) && !b.In(c.Rectangle) {
+				split = true
+				break
+			}
+		}
+		if !split {
+			res = append(res, c)
+		}
+	}
+	if len(res) == 0 {
+		return crops
+	}
+	return res
+}
+
+// TextDetector is a Detector finding blocks of text. Text shows up as a dense
+// cluster of strong vertical strokes, which get joined into blocks of lines
+// wider than tall.
+type TextDetector struct{}
+
+// Detect returns 1 for pixels of img within a text block and 0 otherwise.
+func (d TextDetector) Detect(img *image.RGBA) []float64 {
+	width := img.Bounds().Dx()
+	plane := make([]float64, width*img.Bounds().Dy())
+	for _, b := range d.Blocks(img) {
+		b = b.Sub(img.Bounds().Min)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				plane[y*width+x] = 1
+			}
+		}
+	}
+	return plane
+}
+
+// Blocks returns the bounds of the text blocks found in img.
+func (TextDetector) Blocks(img *image.RGBA) []image.Rectangle {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	if width < 3 || height < 3 {
+		return nil
+	}
+	cies := makeCies(img)
+
+	// pixels with a strong horizontal gradient are likely part of a stroke
+	strokes := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 1; x < width-1; x++ {
+			if d := cies[y*width+x+1] - cies[y*width+x-1]; d > textEdgeThreshold || d < -textEdgeThreshold {
+				strokes[y*width+x] = 1
+			}
+		}
+	}
+
+	density := boxMean(strokes, width, height, textRadius)
+	mask := make([]bool, width*height)
+	for i, v := range density {
+		mask[i] = v >= textDensity
+	}
+
+	var blocks []image.Rectangle
+	for _, c := range components(mask, width, height) {
+		r, area := c.bounds, c.area
+		if r.Dy() < textMinHeight || r.Dx() < r.Dy() || float64(area) < textFill*float64(r.Dx()*r.Dy()) {
+			continue
+		}
+		blocks = append(blocks, r)
+	}
+	blocks = mergeBlocks(blocks)
+
+	for i := range blocks {
+		blocks[i] = blocks[i].Add(img.Bounds().Min)
+	}
+	return blocks
+}
+
+type component struct {
+	bounds image.Rectangle
+	area   int
+}
+
+// components returns the 4-connected components of the set pixels of mask.
+func components(mask []bool, width, height int) []component {
+	seen := make([]bool, len(mask))
+	var res []component
+	var stack []int
+	for start, set := range mask {
+		if !set || seen[start] {
+			continue
+		}
+
+		c := component{bounds: image.Rect(start%width, start/width, start%width+1, start/width+1)}
+		seen[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%width, i/width
+			c.area++
+			c.bounds = c.bounds.Union(image.Rect(x, y, x+1, y+1))
+
+			for _, n := range [4]int{i - 1, i + 1, i - width, i + width} {
+				if n < 0 || n >= len(mask) || !mask[n] || seen[n] {
+					continue
+				}
+				// don't wrap around the left and right edges
+				if (n == i-1 || n == i+1) && n/width != y {
+					continue
+				}
+				seen[n] = true
+				stack = append(stack, n)
+			}
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// mergeBlocks joins blocks which are closer to each other than the height of
+// the smaller one, like the words of a line and the lines of a paragraph.
+func mergeBlocks(blocks []image.Rectangle) []image.Rectangle {
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(blocks) && !merged; i++ {
+			for j := i + 1; j < len(blocks); j++ {
+				gap := blocks[i].Dy()
+				if blocks[j].Dy() < gap {
+					gap = blocks[j].Dy()
+				}
+				if blocks[i].Inset(-gap).Overlaps(blocks[j]) {
+					blocks[i] = blocks[i].Union(blocks[j])
+					blocks = append(blocks[:j], blocks[j+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,256 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package urlcrop
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var imgproxyGravity = map[string]gravity{
+	"ce":   center,
+	"no":   {x: 0.5, y: 0},
+	"so":   {x: 0.5, y: 1},
+	"ea":   {x: 1, y: 0.5},
+	"we":   {x: 0, y: 0.5},
+	"noea": {x: 1, y: 0},
+	"nowe": {x: 0, y: 0},
+	"soea": {x: 1, y: 1},
+	"sowe": {x: 0, y: 1},
+	"sm":   {x: 0.5, y: 0.5, smart: true},
+}
+
+var imgproxyResizingTypes = map[string]resizeMode{
+	"fit":       fit,
+	"fill":      fill,
+	"fill-down": fill,
+	"auto":      fill,
+	"force":     force,
+}
+
+// parseImgproxy parses the part of an imgproxy URL following the signature sig.
+func parseImgproxy(sig, p string, key, salt []byte) (request, error) {
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write(salt)
+		_, _ = mac.Write([]byte("/" + p))
+		expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(sig), []byte(expected)) {
+			return request{}, errSignature
+		}
+	}
+
+	req := newRequest()
+	req.mode = fit
+
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		if s == "plain" {
+			source, err := url.PathUnescape(strings.Join(segs[i+1:], "/"))
+			if err != nil {
+				return request{}, err
+			}
+			if at := strings.LastIndex(source, "@"); at >= 0 {
+				req.format = source[at+1:]
+				source = source[:at]
+			}
+			req.source = source
+			break
+		}
+
+		if !strings.Contains(s, ":") {
+			// base64 encoded source, which may be split by slashes
+			encoded := strings.Join(segs[i:], "")
+			if ext := path.Ext(encoded); ext != "" {
+				req.format = ext[1:]
+				encoded = strings.TrimSuffix(encoded, ext)
+			}
+			source, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+			if err != nil {
+				return request{}, errors.New("invalid source encoding")
+			}
+			req.source = string(source)
+			break
+		}
+
+		if err := req.imgproxyOption(strings.Split(s, ":")); err != nil {
+			return request{}, err
+		}
+	}
+
+	if req.source == "" {
+		return request{}, errors.New("missing image")
+	}
+	if req.format == "jpg" {
+		req.format = "jpeg"
+	}
+	return req, nil
+}
+
+// imgproxyOption applies a single processing option, given as its name
+// followed by its arguments. Unsupported options are ignored.
+func (req *request) imgproxyOption(opt []string) error {
+	name, args := opt[0], opt[1:]
+
+	var err error
+	switch name {
+	case "resize", "rs":
+		if len(args) > 0 && args[0] != "" {
+			if err = req.resizingType(args[0]); err != nil {
+				return err
+			}
+		}
+		if len(args) > 1 {
+			return req.imgproxyOption(append([]string{"size"}, args[1:]...))
+		}
+
+	case "size", "s":
+		if len(args) > 0 && args[0] != "" {
+			req.width, err = parseInt("width", args[0])
+		}
+		if err == nil && len(args) > 1 && args[1] != "" {
+			req.height, err = parseInt("height", args[1])
+		}
+		if err == nil && len(args) > 2 && args[2] != "" {
+			req.enlarge = parseBool(args[2])
+		}
+
+	case "resizing_type", "rt":
+		if len(args) > 0 {
+			err = req.resizingType(args[0])
+		}
+
+	case "width", "w":
+		if len(args) > 0 {
+			req.width, err = parseInt("width", args[0])
+		}
+
+	case "height", "h":
+		if len(args) > 0 {
+			req.height, err = parseInt("height", args[0])
+		}
+
+	case "enlarge", "el":
+		req.enlarge = len(args) > 0 && parseBool(args[0])
+
+	case "gravity", "g":
+		req.gravity, err = parseGravity(args)
+
+	case "crop", "c":
+		if len(args) > 0 {
+			req.cropWidth, err = parseFloat("crop width", args[0])
+		}
+		if err == nil && len(args) > 1 {
+			req.cropHeight, err = parseFloat("crop height", args[1])
+		}
+		if err == nil && len(args) > 2 {
+			var g gravity
+			g, err = parseGravity(args[2:])
+			req.cropGravity = &g
+		}
+
+	case "quality", "q":
+		if len(args) > 0 {
+			req.quality, err = parseInt("quality", args[0])
+			if err == nil && (req.quality < 1 || req.quality > 100) {
+				err = fmt.Errorf("invalid quality %q", args[0])
+			}
+		}
+
+	case "format", "f", "ext":
+		if len(args) > 0 {
+			req.format = args[0]
+		}
+	}
+
+	return err
+}
+
+func (req *request) resizingType(t string) error {
+	mode, ok := imgproxyResizingTypes[t]
+	if !ok {
+		return fmt.Errorf("unknown resizing type %q", t)
+	}
+	req.mode = mode
+	if t == "fill-down" {
+		req.enlarge = false
+	}
+	return nil
+}
+
+func parseGravity(args []string) (gravity, error) {
+	if len(args) == 0 {
+		return center, nil
+	}
+	if args[0] == "fp" {
+		if len(args) != 3 {
+			return gravity{}, errors.New("focus point gravity needs x and y")
+		}
+		x, err := parseFloat("focus point", args[1])
+		if err != nil {
+			return gravity{}, err
+		}
+		y, err := parseFloat("focus point", args[2])
+		if err != nil {
+			return gravity{}, err
+		}
+		return gravity{x: math.Min(x, 1), y: math.Min(y, 1), focus: true}, nil
+	}
+
+	g, ok := imgproxyGravity[args[0]]
+	if !ok {
+		return gravity{}, fmt.Errorf("unknown gravity %q", args[0])
+	}
+	return g, nil
+}
+
+func parseInt(name, s string) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return i, nil
+}
+
+func parseFloat(name, s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return f, nil
+}
+
+func parseBool(s string) bool {
+	return s == "1" || s == "t" || s == "true"
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package urlcrop
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/muesli/smartcrop/internal/serve"
+)
+
+// ErrNotFound gets returned by an Origin when the requested image doesn't exist.
+var ErrNotFound = serve.ErrNotFound
+
+// Origin loads source images.
+type Origin interface {
+	// Open returns the image at source, as given in the processing URL.
+	Open(source string) (io.ReadCloser, error)
+}
+
+// Dir is an Origin loading images from a local directory. Sources may be
+// prefixed with "local://", as used by imgproxy for local files. An empty Dir
+// doesn't find any images.
+type Dir string
+
+// Open opens the image source within the directory.
+func (d Dir) Open(source string) (io.ReadCloser, error) {
+	if d == "" {
+		return nil, ErrNotFound
+	}
+	source = strings.TrimPrefix(source, "local://")
+	// cleaning the path as an absolute one strips any attempt to leave d
+	p := path.Clean("/" + source)
+	if p == "/" {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(string(d), filepath.FromSlash(p)))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, ErrNotFound
+	}
+	return f, nil
+}
@@ -0,0 +1,436 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+/*
+Package urlcrop provides an http.Handler understanding the processing URLs of
+thumbor and imgproxy, so smartcrop can replace them without changing clients.
+
+Thumbor URLs look like
+
+	/unsafe/[trim/][AxB:CxD/][fit-in/][-]Ex[-]F/[HALIGN/][VALIGN/][smart/][filters:...]/IMAGE
+
+and support manual crops, fit-in, flipping, alignment, smart cropping and the
+format() and quality() filters. Other filters are ignored.
+
+imgproxy URLs look like
+
+	/insecure/rs:fill:300:200/g:sm/plain/local:///path/image.jpg@png
+	/insecure/rs:fill:300:200/g:sm/<base64 encoded source>.png
+
+and support the resize, size, resizing_type, width, height, enlarge, gravity
+(including sm and fp), crop, quality and format options. Other options are
+ignored.
+
+The "smart" alignment of thumbor and the "sm" gravity of imgproxy use the
+smartcrop analyzer. Which syntax a URL uses is decided by its first segment:
+"unsafe" or a 28 character signature for thumbor, "insecure", "_" or a 43
+character signature for imgproxy. Signatures are only checked if the
+respective key is set. Once either key is set, URLs of the other syntax are
+rejected unless its key is set as well. Images requested as WebP get delivered
+as JPEG, as there is no WebP encoder.
+*/
+package urlcrop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/internal/codec"
+	"github.com/muesli/smartcrop/internal/serve"
+	"github.com/muesli/smartcrop/nfnt"
+	"github.com/muesli/smartcrop/options"
+)
+
+const (
+	// DefaultMaxBytes is the default size limit of source images.
+	DefaultMaxBytes = serve.DefaultMaxBytes
+	// DefaultMaxPixels is the default limit of the pixel count of source
+	// images and of responses.
+	DefaultMaxPixels = serve.DefaultMaxPixels
+	// DefaultMaxAge is the default lifetime of responses in caches.
+	DefaultMaxAge = serve.DefaultMaxAge
+
+	defaultQuality = 80
+)
+
+var errSignature = errors.New("invalid signature")
+
+// Handler is an http.Handler serving thumbor and imgproxy style URLs.
+type Handler struct {
+	// Origin loads the source images.
+	Origin Origin
+	// Analyzer finds the crops for smart gravity.
+	Analyzer smartcrop.Analyzer
+	// Resizer scales the images.
+	Resizer options.Resizer
+
+	// ThumborKey is the security key used to sign thumbor URLs. If neither
+	// it nor ImgproxyKey is set, the signature isn't checked. If only
+	// ImgproxyKey is set, thumbor URLs are rejected.
+	ThumborKey []byte
+	// ImgproxyKey and ImgproxySalt are used to sign imgproxy URLs. If neither
+	// the key nor ThumborKey is set, the signature isn't checked. If only
+	// ThumborKey is set, imgproxy URLs are rejected.
+	ImgproxyKey  []byte
+	ImgproxySalt []byte
+
+	// MaxBytes limits the size of source images.
+	MaxBytes int64
+	// MaxPixels limits the pixel count of source images and of responses.
+	MaxPixels int
+	// MaxAge is the lifetime of responses announced in the Cache-Control
+	// header.
+	MaxAge time.Duration
+}
+
+// NewHandler returns a Handler loading images from origin, with the default
+// analyzer and limits.
+func NewHandler(origin Origin) *Handler {
+	resizer := nfnt.NewDefaultResizer()
+	return &Handler{
+		Origin:    origin,
+		Analyzer:  smartcrop.NewAnalyzer(resizer),
+		Resizer:   resizer,
+		MaxBytes:  DefaultMaxBytes,
+		MaxPixels: DefaultMaxPixels,
+		MaxAge:    DefaultMaxAge,
+	}
+}
+
+type resizeMode int
+
+const (
+	// fill crops the image to the requested aspect ratio before scaling it
+	fill resizeMode = iota
+	// fit scales the image to fit into the requested size
+	fit
+	// force scales the image to the requested size, ignoring its aspect ratio
+	force
+)
+
+// gravity decides where a crop is placed. x and y position the crop within
+// the free space, from 0 (left / top) to 1 (right / bottom). For a focus
+// gravity they are the relative position of the point the crop gets centered
+// on instead. A smart gravity lets the analyzer choose.
+type gravity struct {
+	x, y  float64
+	focus bool
+	smart bool
+}
+
+var center = gravity{x: 0.5, y: 0.5}
+
+// request is a parsed processing URL.
+type request struct {
+	source string
+
+	// manual crop in pixels, applied first
+	crop image.Rectangle
+	// crop of the given size placed by cropGravity (or gravity, if nil),
+	// applied after crop. Values below 1 are relative to the image size.
+	cropWidth, cropHeight float64
+	cropGravity           *gravity
+
+	width, height int
+	mode          resizeMode
+	enlarge       bool
+	gravity       gravity
+	flipX, flipY  bool
+
+	format  string
+	quality int
+}
+
+func newRequest() request {
+	return request{
+		gravity: center,
+		quality: defaultQuality,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.parse(r.URL.EscapedPath())
+	if err == errSignature {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, format, err := h.load(req.source)
+	if err != nil {
+		serve.Error(w, err, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	out, err := h.process(img, req)
+	if err != nil {
+		serve.Error(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if req.format == "" {
+		req.format = format
+	}
+	if !codec.CanEncode(req.format) {
+		req.format = "jpeg"
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, out, req.format, req.quality); err != nil {
+		http.Error(w, "can't encode image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", codec.ContentType(req.format))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.limits().Age().Seconds())))
+	if r.Method != http.MethodHead {
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// parse parses a thumbor or imgproxy processing URL.
+func (h *Handler) parse(p string) (request, error) {
+	p = strings.TrimPrefix(p, "/")
+	i := strings.Index(p, "/")
+	if i < 0 {
+		return request{}, errors.New("invalid processing URL")
+	}
+	sig, rest := p[:i], p[i+1:]
+	// with any key set, unsigned URLs of the other syntax must not get through
+	signed := len(h.ThumborKey) > 0 || len(h.ImgproxyKey) > 0
+
+	switch {
+	case sig == "unsafe" || len(sig) == 28:
+		if signed && len(h.ThumborKey) == 0 {
+			return request{}, errSignature
+		}
+		return parseThumbor(sig, rest, h.ThumborKey)
+	case sig == "insecure" || sig == "_" || len(sig) == 43:
+		if signed && len(h.ImgproxyKey) == 0 {
+			return request{}, errSignature
+		}
+		return parseImgproxy(sig, rest, h.ImgproxyKey, h.ImgproxySalt)
+	}
+	return request{}, errors.New("unknown processing URL syntax")
+}
+
+// load reads and decodes a source image from the origin.
+func (h *Handler) load(source string) (image.Image, string, error) {
+	if h.Origin == nil {
+		return nil, "", ErrNotFound
+	}
+	rc, err := h.Origin.Open(source)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close() //nolint:errcheck // read-only source
+
+	return h.limits().Load(rc)
+}
+
+// process applies the crops, resizing and flipping of req to img.
+func (h *Handler) process(img image.Image, req request) (image.Image, error) {
+	if !req.crop.Empty() {
+		r := req.crop.Add(img.Bounds().Min).Intersect(img.Bounds())
+		if r.Empty() {
+			return nil, errors.New("crop outside of the image")
+		}
+		img = serve.SubImage(img, r)
+	}
+
+	if req.cropWidth > 0 || req.cropHeight > 0 {
+		b := img.Bounds()
+		cw, ch := relative(req.cropWidth, b.Dx()), relative(req.cropHeight, b.Dy())
+		g := req.gravity
+		if req.cropGravity != nil {
+			g = *req.cropGravity
+		}
+		r, err := h.place(img, cw, ch, g, false)
+		if err != nil {
+			return nil, err
+		}
+		img = serve.SubImage(img, r)
+	}
+
+	b := img.Bounds()
+	width, height := req.width, req.height
+	switch {
+	case width == 0 && height == 0:
+		// keep the size
+		width, height = b.Dx(), b.Dy()
+
+	case width == 0 || height == 0 || req.mode == fit:
+		scale := math.Inf(1)
+		if width > 0 {
+			scale = float64(width) / float64(b.Dx())
+		}
+		if height > 0 {
+			scale = math.Min(scale, float64(height)/float64(b.Dy()))
+		}
+		if !req.enlarge {
+			scale = math.Min(scale, 1)
+		}
+		width = int(math.Max(1, math.Round(float64(b.Dx())*scale)))
+		height = int(math.Max(1, math.Round(float64(b.Dy())*scale)))
+
+	case req.mode == fill:
+		r, err := h.place(img, width, height, req.gravity, true)
+		if err != nil {
+			return nil, err
+		}
+		img = serve.SubImage(img, r)
+		b = img.Bounds()
+		if !req.enlarge && (width > b.Dx() || height > b.Dy()) {
+			width, height = b.Dx(), b.Dy()
+		}
+	}
+
+	if width != b.Dx() || height != b.Dy() {
+		if err := h.limits().CheckSize(width, height); err != nil {
+			return nil, err
+		}
+		img = serve.Resizer(h.Resizer).Resize(img, uint(width), uint(height))
+	}
+	if req.flipX || req.flipY {
+		img = flip(img, req.flipX, req.flipY)
+	}
+	return img, nil
+}
+
+// place returns a crop of img. If largest is set, the crop is the largest
+// area with the aspect ratio width:height, otherwise it has exactly that size
+// (or less, for small images).
+func (h *Handler) place(img image.Image, width, height int, g gravity, largest bool) (image.Rectangle, error) {
+	b := img.Bounds()
+	cw, ch := width, height
+	if largest {
+		cw, ch = b.Dx(), b.Dx()*height/width
+		if ch > b.Dy() {
+			cw, ch = b.Dy()*width/height, b.Dy()
+		}
+	}
+	cw, ch = minInt(cw, b.Dx()), minInt(ch, b.Dy())
+	if cw < 1 || ch < 1 {
+		return image.Rectangle{}, errors.New("invalid crop size")
+	}
+
+	if g.smart {
+		analyzer := h.Analyzer
+		if analyzer == nil {
+			analyzer = smartcrop.NewAnalyzer(serve.Resizer(h.Resizer))
+		}
+		r, err := analyzer.FindBestCrop(img, cw, ch)
+		if err != nil {
+			return image.Rectangle{}, err
+		}
+		if largest {
+			return r, nil
+		}
+		// center a crop of the exact size on the analyzer's choice
+		c := r.Min.Add(r.Max).Div(2)
+		x := clamp(c.X-cw/2, b.Min.X, b.Max.X-cw)
+		y := clamp(c.Y-ch/2, b.Min.Y, b.Max.Y-ch)
+		return image.Rect(x, y, x+cw, y+ch), nil
+	}
+
+	var x, y int
+	if g.focus {
+		x = clamp(b.Min.X+int(math.Round(float64(b.Dx())*g.x))-cw/2, b.Min.X, b.Max.X-cw)
+		y = clamp(b.Min.Y+int(math.Round(float64(b.Dy())*g.y))-ch/2, b.Min.Y, b.Max.Y-ch)
+	} else {
+		x = b.Min.X + int(math.Round(float64(b.Dx()-cw)*g.x))
+		y = b.Min.Y + int(math.Round(float64(b.Dy()-ch)*g.y))
+	}
+	return image.Rect(x, y, x+cw, y+ch), nil
+}
+
+func (h *Handler) limits() serve.Limits {
+	return serve.Limits{MaxBytes: h.MaxBytes, MaxPixels: h.MaxPixels, MaxAge: h.MaxAge}
+}
+
+func flip(img image.Image, flipX, flipY bool) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			sx, sy := x, y
+			if flipX {
+				sx = b.Dx() - 1 - x
+			}
+			if flipY {
+				sy = b.Dy() - 1 - y
+			}
+			out.Set(x, y, color.RGBAModel.Convert(img.At(b.Min.X+sx, b.Min.Y+sy)))
+		}
+	}
+	return out
+}
+
+// relative converts v to pixels: values below 1 are a fraction of size, 0
+// means the full size.
+func relative(v float64, size int) int {
+	switch {
+	case v <= 0:
+		return size
+	case v < 1:
+		return int(math.Round(v * float64(size)))
+	}
+	return int(v)
+}
+
+func clamp(v, lo, hi int) int {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2014-2026 Christian Muehlhaeuser
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ *	Authors:
+ *		Christian Muehlhaeuser <muesli@gmail.com>
+ */
+
+package urlcrop
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	smart := gravity{x: 0.5, y: 0.5, smart: true}
+	tests := []struct {
+		url      string
+		expected request
+	}{
+		{
+			url: "/unsafe/300x200/smart/path/gopher.jpg",
+			expected: request{source: "path/gopher.jpg", width: 300, height: 200,
+				enlarge: true, gravity: smart, quality: 80},
+		},
+		{
+			url: "/unsafe/10x20:110x120/fit-in/-300x0/left/top/filters:format(png):quality(90)/gopher.jpg",
+			expected: request{source: "gopher.jpg", crop: image.Rect(10, 20, 110, 120), width: 300,
+				mode: fit, flipX: true, gravity: gravity{}, format: "png", quality: 90},
+		},
+		{
+			url: "/insecure/rs:fill:300:200/g:sm/plain/local:///gopher.jpg@png",
+			expected: request{source: "local:///gopher.jpg", width: 300, height: 200,
+				gravity: smart, format: "png", quality: 80},
+		},
+		{
+			url: "/_/w:300/q:70/" + base64.RawURLEncoding.EncodeToString([]byte("gopher.jpg")) + ".jpg",
+			expected: request{source: "gopher.jpg", width: 300, mode: fit,
+				gravity: center, format: "jpeg", quality: 70},
+		},
+	}
+
+	h := &Handler{}
+	for _, test := range tests {
+		req, err := h.parse(test.url)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(req, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.url, test.expected, req)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	h := &Handler{ImgproxyKey: []byte("key"), ImgproxySalt: []byte("salt")}
+
+	p := "/rs:fill:300:200/plain/gopher.jpg"
+	mac := hmac.New(sha256.New, h.ImgproxyKey)
+	_, _ = mac.Write(h.ImgproxySalt)
+	_, _ = mac.Write([]byte(p))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	if _, err := h.parse("/" + sig + p); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if _, err := h.parse("/insecure" + p); err != errSignature {
+		t.Errorf("expected %v, got %v", errSignature, err)
+	}
+	if _, err := h.parse("/unsafe/300x200/smart/gopher.jpg"); err != errSignature {
+		t.Errorf("expected unsigned thumbor URLs to be rejected, got %v", err)
+	}
+}
+
+func TestSignatureThumborOnly(t *testing.T) {
+	h := NewHandler(Dir("../examples"))
+	h.ThumborKey = []byte("key")
+
+	for _, u := range []string{
+		"/unsafe/300x200/smart/gopher.jpg",
+		"/insecure/rs:fill:300:200/g:sm/plain/gopher.jpg",
+		"/_/rs:fill:300:200/plain/gopher.jpg",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", u, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403, got %d", u, rec.Code)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHandler(Dir("../examples"))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/unsafe/250x250/smart/filters:format(png)/gopher.jpg", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	img, format, err := image.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || img.Bounds().Dx() != 250 || img.Bounds().Dy() != 250 {
+		t.Fatalf("expected a 250x250 png, got a %dx%d %s", img.Bounds().Dx(), img.Bounds().Dy(), format)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/unsafe/250x250/smart/../smartcrop.go", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 when leaving the origin, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/unsafe/30000x30000/gopher.jpg", nil))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413 for a huge output, got %d", rec.Code)
+	}
+}
+
+func TestEmptyDir(t *testing.T) {
+	p, err := filepath.Abs("../examples/gopher.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an empty directory must not resolve sources from the filesystem root
+	if _, err := Dir("").Open(p); err != ErrNotFound {
+		t.Fatalf("expected %v, got %v", ErrNotFound, err)
+	}
+	rc, err := Dir("../examples").Open("gopher.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rc.Close()
+}
+
+func TestManualCropAndSmart(t *testing.T) {
+	h := NewHandler(Dir("../examples"))
+	for _, u := range []string{
+		"/unsafe/500x0:900x284/200x100/smart/gopher.jpg",
+		"/unsafe/300x100:900x284/100x100/smart/gopher.jpg",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", u, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", u, rec.Code, rec.Body)
+		}
+		img, _, err := image.Decode(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := h.parse(u)
+		if img.Bounds().Dx() != req.width || img.Bounds().Dy() != req.height {
+			t.Fatalf("%s: expected a %dx%d image, got %v", u, req.width, req.height, img.Bounds())
+		}
+	}
+
+	// a colourful square on a plain background, the manual crop leaves its
+	// left half out
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{128, 128, 128, 255}
+			if x >= 300 && x < 360 && y >= 50 && y < 110 {
+				c = color.RGBA{uint8(x * 7), uint8(y * 13), 200, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	req, err := h.parse("/unsafe/200x0:400x200/100x100/smart/x.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := h.process(img, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := out.At(out.Bounds().Min.X+50, out.Bounds().Min.Y+50).RGBA(); r == g && g == b {
+		t.Fatalf("expected the square in the centre of the crop, got grey")
+	}
+}